@@ -13,6 +13,5 @@ func main() {
 	lambda.Start(Handler)
 }
 func Handler(ctx context.Context, event re.QueryRequest) (re.Response, error) {
-	response := query.Query(ctx, event)
-	return response, nil
+	return query.Query(ctx, event)
 }
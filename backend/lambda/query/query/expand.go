@@ -0,0 +1,328 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"hugoembedding/vectorstore"
+)
+
+// ExpandMode is the unit a found chunk is expanded to
+type ExpandMode string
+
+const (
+	// ExpandChunk keeps the found chunk
+	ExpandChunk ExpandMode = "chunk"
+	// ExpandWindow adds the neighbour chunks
+	ExpandWindow ExpandMode = "window"
+	// ExpandSection adds the chunks under the same heading
+	ExpandSection ExpandMode = "section"
+	// ExpandPost takes the whole post if it is short, otherwise the section
+	ExpandPost ExpandMode = "post"
+)
+
+// ExpandOptions configures the small-to-big retrieval
+type ExpandOptions struct {
+	Mode ExpandMode
+	// Window is the number of neighbours on each side
+	Window int
+	// MaxSectionChunks limits the expansion to a section
+	MaxSectionChunks int
+	// MaxPostChars is the size up to which a whole post is used
+	MaxPostChars int
+	// Budget is the maximum size of all passages in characters
+	Budget int
+}
+
+// DefaultExpandOptions expand to the heading section
+var DefaultExpandOptions = ExpandOptions{
+	Mode:             ExpandSection,
+	Window:           1,
+	MaxSectionChunks: 12,
+	MaxPostChars:     6000,
+	Budget:           16000,
+}
+
+// ExpandOptionsFromEnv reads EXPAND_MODE, EXPAND_WINDOW, EXPAND_POST_CHARS
+// and CONTEXT_BUDGET, an unknown mode is an error
+func ExpandOptionsFromEnv() (ExpandOptions, error) {
+	options := DefaultExpandOptions
+	if mode := os.Getenv("EXPAND_MODE"); mode != "" {
+		options.Mode = ExpandMode(mode)
+	}
+	switch options.Mode {
+	case ExpandChunk, ExpandWindow, ExpandSection, ExpandPost:
+	default:
+		return options, fmt.Errorf("unknown expand mode %q, use chunk, window, section or post", options.Mode)
+	}
+	if window, err := strconv.Atoi(os.Getenv("EXPAND_WINDOW")); err == nil {
+		options.Window = window
+	}
+	if chars, err := strconv.Atoi(os.Getenv("EXPAND_POST_CHARS")); err == nil {
+		options.MaxPostChars = chars
+	}
+	if budget, err := strconv.Atoi(os.Getenv("CONTEXT_BUDGET")); err == nil {
+		options.Budget = budget
+	}
+	return options, nil
+}
+
+// Passage is a part of a post around one or more found chunks
+type Passage struct {
+	// Hits are the found chunks, the best first
+	Hits       []vectorstore.Result
+	Similarity float32
+	Title      string
+	Link       string
+	Text       string
+
+	source      string
+	first, last int
+}
+
+// DocumentStore reads the chunks of a post, the vector stores implement it
+type DocumentStore interface {
+	// GetMany returns the documents with the IDs by ID, IDs without a
+	// document are missing in the map
+	GetMany(ctx context.Context, ids []string) (map[string]*vectorstore.Document, error)
+}
+
+// maxPostChunks stops the search for the end of a post
+const maxPostChunks = 500
+
+// postBlockChunks are the chunks of a post which are fetched at once
+const postBlockChunks = 50
+
+// Expand turns the found chunks into passages of the configured unit.
+// Hits of the same post with overlapping passages are merged, the passages
+// are cut down to stay within the budget. The best passage is kept with at
+// least its best chunk, even if the chunk alone exceeds the budget.
+func Expand(ctx context.Context, store DocumentStore, results []vectorstore.Result, options ExpandOptions) []Passage {
+	documents := documentCache{store: store, ctx: ctx, documents: map[string]*vectorstore.Document{}}
+
+	var passages []*Passage
+	for _, result := range results {
+		passage := &Passage{
+			Hits:       []vectorstore.Result{result},
+			Similarity: result.Similarity,
+			Title:      result.Metadata["title"],
+			Link:       result.Metadata["link"],
+		}
+		source, ordinal, ok := parseDocumentID(result.ID)
+		if !ok {
+			// documents of an older import can not be expanded
+			passage.Text = result.Content
+			passages = append(passages, passage)
+			continue
+		}
+		passage.source = source
+		passage.first, passage.last = documents.expand(source, ordinal, result.Metadata["heading_path"], options)
+		passages = mergePassage(passages, passage)
+	}
+
+	result := make([]Passage, 0, len(passages))
+	remaining := options.Budget
+	fits := func(text string) bool {
+		return options.Budget <= 0 || len(text) <= remaining
+	}
+	for _, passage := range passages {
+		if passage.source != "" {
+			passage.Text = documents.text(passage.source, passage.first, passage.last)
+			if !fits(passage.Text) {
+				// only the found chunks and their direct neighbours
+				first, last := passage.hitRange()
+				passage.Text = documents.text(passage.source, max(first-1, passage.first), min(last+1, passage.last))
+				if !fits(passage.Text) {
+					passage.Text = documents.text(passage.source, first, last)
+				}
+				if !fits(passage.Text) {
+					best := passage.bestHit()
+					passage.Text = documents.text(passage.source, best, best)
+				}
+			}
+		}
+		// the best passage is kept with at least its best chunk
+		if !fits(passage.Text) && len(result) > 0 {
+			continue
+		}
+		remaining -= len(passage.Text)
+		result = append(result, *passage)
+	}
+	return result
+}
+
+// mergePassage adds the passage or merges it into an overlapping or
+// adjacent passage of the same post
+func mergePassage(passages []*Passage, passage *Passage) []*Passage {
+	for _, existing := range passages {
+		if existing.source != passage.source || existing.source == "" {
+			continue
+		}
+		if passage.first > existing.last+1 || passage.last < existing.first-1 {
+			continue
+		}
+		existing.first = min(existing.first, passage.first)
+		existing.last = max(existing.last, passage.last)
+		existing.Hits = append(existing.Hits, passage.Hits...)
+		if passage.Similarity > existing.Similarity {
+			existing.Similarity = passage.Similarity
+		}
+		return passages
+	}
+	return append(passages, passage)
+}
+
+// hitRange is the range of the found chunks of the passage
+func (p *Passage) hitRange() (int, int) {
+	first, last := p.last, p.first
+	for _, hit := range p.Hits {
+		if _, ordinal, ok := parseDocumentID(hit.ID); ok {
+			first = min(first, ordinal)
+			last = max(last, ordinal)
+		}
+	}
+	return first, last
+}
+
+// bestHit is the chunk of the first hit, the hits are ordered by similarity
+func (p *Passage) bestHit() int {
+	_, ordinal, _ := parseDocumentID(p.Hits[0].ID)
+	return ordinal
+}
+
+// documentCache fetches the chunks of a post by ID once
+type documentCache struct {
+	store     DocumentStore
+	ctx       context.Context
+	documents map[string]*vectorstore.Document
+}
+
+func (d *documentCache) get(source string, ordinal int) *vectorstore.Document {
+	if ordinal < 0 {
+		return nil
+	}
+	d.fetch(source, ordinal, ordinal)
+	return d.documents[documentID(source, ordinal)]
+}
+
+// fetch reads the chunks of the range which are not cached in one lookup
+func (d *documentCache) fetch(source string, first, last int) {
+	var ids []string
+	for i := max(first, 0); i <= last; i++ {
+		id := documentID(source, i)
+		if _, ok := d.documents[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	// a missing or failed document ends the expansion
+	found, err := d.store.GetMany(d.ctx, ids)
+	if err != nil {
+		found = nil
+	}
+	for _, id := range ids {
+		d.documents[id] = found[id]
+	}
+}
+
+// expand returns the range of chunks around the found chunk
+func (d *documentCache) expand(source string, ordinal int, headingPath string, options ExpandOptions) (int, int) {
+	switch options.Mode {
+	case ExpandWindow:
+		return ordinal - options.Window, ordinal + options.Window
+	case ExpandPost:
+		size := 0
+		last := -1
+		for i := 0; i < maxPostChunks && size <= options.MaxPostChars; i++ {
+			if i%postBlockChunks == 0 {
+				d.fetch(source, i, min(i+postBlockChunks, maxPostChunks)-1)
+			}
+			document := d.get(source, i)
+			if document == nil {
+				break
+			}
+			size += len(document.Content)
+			last = i
+		}
+		if last >= 0 && size <= options.MaxPostChars {
+			return 0, last
+		}
+		return d.section(source, ordinal, headingPath, options.MaxSectionChunks)
+	case ExpandSection:
+		return d.section(source, ordinal, headingPath, options.MaxSectionChunks)
+	}
+	return ordinal, ordinal
+}
+
+// section extends the range while the chunks have the same heading path
+func (d *documentCache) section(source string, ordinal int, headingPath string, limit int) (int, int) {
+	first, last := ordinal, ordinal
+	d.fetch(source, ordinal-limit+1, ordinal+limit-1)
+	sameSection := func(i int) bool {
+		document := d.get(source, i)
+		return document != nil && document.Metadata["heading_path"] == headingPath
+	}
+	for last-first+1 < limit {
+		extended := false
+		if sameSection(first - 1) {
+			first--
+			extended = true
+		}
+		if last-first+1 < limit && sameSection(last+1) {
+			last++
+			extended = true
+		}
+		if !extended {
+			break
+		}
+	}
+	return first, last
+}
+
+// text joins the chunks of the range, the heading path line is only kept
+// where the section changes
+func (d *documentCache) text(source string, first, last int) string {
+	var text strings.Builder
+	previousHeading := ""
+	d.fetch(source, first, last)
+	for i := max(first, 0); i <= last; i++ {
+		document := d.get(source, i)
+		if document == nil {
+			continue
+		}
+		content := document.Content
+		heading := document.Metadata["heading_path"]
+		if heading != "" && heading == previousHeading {
+			content = strings.TrimPrefix(content, heading+"\n")
+		}
+		previousHeading = heading
+		text.WriteString(content)
+		if !strings.HasSuffix(content, "\n") {
+			text.WriteString("\n")
+		}
+	}
+	return text.String()
+}
+
+// parseDocumentID splits the IDs of the import, "3f2a9c1b7d4e-0007"
+// is the chunk 7 of the source with the hash 3f2a9c1b7d4e
+func parseDocumentID(id string) (string, int, bool) {
+	separator := strings.LastIndex(id, "-")
+	if separator <= 0 {
+		return "", 0, false
+	}
+	ordinal, err := strconv.Atoi(id[separator+1:])
+	if err != nil {
+		return "", 0, false
+	}
+	return id[:separator], ordinal, true
+}
+
+func documentID(source string, ordinal int) string {
+	return fmt.Sprintf("%v-%04d", source, ordinal)
+}
@@ -0,0 +1,148 @@
+package query_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"hugoembedding/vectorstore"
+	"ragembeddings/query"
+
+	"gotest.tools/v3/assert"
+)
+
+// memoryStore has the documents by ID
+type memoryStore map[string]vectorstore.Document
+
+func (m memoryStore) GetMany(ctx context.Context, ids []string) (map[string]*vectorstore.Document, error) {
+	documents := map[string]*vectorstore.Document{}
+	for _, id := range ids {
+		if document, ok := m[id]; ok {
+			documents[id] = &document
+		}
+	}
+	return documents, nil
+}
+
+// post stores the chunks of a post with their heading paths, the content
+// of a chunk is its ordinal
+func post(source string, headings ...string) memoryStore {
+	store := memoryStore{}
+	for i, heading := range headings {
+		id := fmt.Sprintf("%v-%04d", source, i)
+		store[id] = vectorstore.Document{
+			ID:       id,
+			Content:  fmt.Sprintf("%d\n", i),
+			Metadata: map[string]string{"heading_path": heading, "title": source},
+		}
+	}
+	return store
+}
+
+func hit(store memoryStore, ordinal int, similarity float32) vectorstore.Result {
+	id := fmt.Sprintf("aaa-%04d", ordinal)
+	return vectorstore.Result{Document: store[id], Similarity: similarity}
+}
+
+func TestExpand(t *testing.T) {
+	store := post("aaa", "Intro", "Setup", "Setup", "Setup", "Usage", "Usage")
+	// each chunk has two characters
+	tests := []struct {
+		name    string
+		options query.ExpandOptions
+		hits    []vectorstore.Result
+		// texts of the passages
+		texts []string
+	}{
+		{"chunk", query.ExpandOptions{Mode: query.ExpandChunk},
+			[]vectorstore.Result{hit(store, 2, 0.9)}, []string{"2\n"}},
+		{"window", query.ExpandOptions{Mode: query.ExpandWindow, Window: 1},
+			[]vectorstore.Result{hit(store, 2, 0.9)}, []string{"1\n2\n3\n"}},
+		{"window at the first chunk", query.ExpandOptions{Mode: query.ExpandWindow, Window: 2},
+			[]vectorstore.Result{hit(store, 0, 0.9)}, []string{"0\n1\n2\n"}},
+		{"window at the last chunk", query.ExpandOptions{Mode: query.ExpandWindow, Window: 1},
+			[]vectorstore.Result{hit(store, 5, 0.9)}, []string{"4\n5\n"}},
+		{"section", query.ExpandOptions{Mode: query.ExpandSection, MaxSectionChunks: 12},
+			[]vectorstore.Result{hit(store, 2, 0.9)}, []string{"1\n2\n3\n"}},
+		{"section limit", query.ExpandOptions{Mode: query.ExpandSection, MaxSectionChunks: 2},
+			[]vectorstore.Result{hit(store, 2, 0.9)}, []string{"1\n2\n"}},
+		{"short post", query.ExpandOptions{Mode: query.ExpandPost, MaxPostChars: 100, MaxSectionChunks: 12},
+			[]vectorstore.Result{hit(store, 2, 0.9)}, []string{"0\n1\n2\n3\n4\n5\n"}},
+		{"long post", query.ExpandOptions{Mode: query.ExpandPost, MaxPostChars: 4, MaxSectionChunks: 12},
+			[]vectorstore.Result{hit(store, 4, 0.9)}, []string{"4\n5\n"}},
+		{"overlapping windows merge", query.ExpandOptions{Mode: query.ExpandWindow, Window: 1},
+			[]vectorstore.Result{hit(store, 1, 0.9), hit(store, 3, 0.8)}, []string{"0\n1\n2\n3\n4\n"}},
+		{"adjacent sections merge", query.ExpandOptions{Mode: query.ExpandSection, MaxSectionChunks: 12},
+			[]vectorstore.Result{hit(store, 4, 0.9), hit(store, 2, 0.8)}, []string{"1\n2\n3\n4\n5\n"}},
+		{"distant chunks stay apart", query.ExpandOptions{Mode: query.ExpandChunk},
+			[]vectorstore.Result{hit(store, 5, 0.9), hit(store, 1, 0.8)}, []string{"5\n", "1\n"}},
+		{"budget keeps the hits and their neighbours", query.ExpandOptions{Mode: query.ExpandPost, MaxPostChars: 100, Budget: 6},
+			[]vectorstore.Result{hit(store, 2, 0.9)}, []string{"1\n2\n3\n"}},
+		{"budget keeps the hits", query.ExpandOptions{Mode: query.ExpandPost, MaxPostChars: 100, Budget: 4},
+			[]vectorstore.Result{hit(store, 2, 0.9), hit(store, 3, 0.8)}, []string{"2\n3\n"}},
+		{"budget keeps the best hit", query.ExpandOptions{Mode: query.ExpandPost, MaxPostChars: 100, Budget: 2},
+			[]vectorstore.Result{hit(store, 3, 0.9), hit(store, 1, 0.8)}, []string{"3\n"}},
+		{"best hit over budget", query.ExpandOptions{Mode: query.ExpandSection, MaxSectionChunks: 12, Budget: 1},
+			[]vectorstore.Result{hit(store, 2, 0.9), hit(store, 5, 0.8)}, []string{"2\n"}},
+		{"budget drops the other passages", query.ExpandOptions{Mode: query.ExpandChunk, Budget: 3},
+			[]vectorstore.Result{hit(store, 0, 0.9), hit(store, 5, 0.8)}, []string{"0\n"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			passages := query.Expand(context.Background(), store, test.hits, test.options)
+			texts := []string{}
+			for _, passage := range passages {
+				texts = append(texts, passage.Text)
+				assert.Equal(t, passage.Hits[0].Similarity, passage.Similarity)
+				assert.Equal(t, passage.Title, "aaa")
+			}
+			assert.DeepEqual(t, texts, test.texts)
+		})
+	}
+}
+
+func TestExpandOlderImport(t *testing.T) {
+	// IDs without ordinal are not expanded
+	result := vectorstore.Result{Document: vectorstore.Document{ID: "chunk", Content: "older import\n"}}
+	passages := query.Expand(context.Background(), memoryStore{}, []vectorstore.Result{result}, query.ExpandOptions{Mode: query.ExpandPost, Budget: 4})
+	assert.Equal(t, len(passages), 1)
+	assert.Equal(t, passages[0].Text, "older import\n")
+}
+
+// countingStore counts the lookups of the documents
+type countingStore struct {
+	memoryStore
+	lookups int
+}
+
+func (c *countingStore) GetMany(ctx context.Context, ids []string) (map[string]*vectorstore.Document, error) {
+	c.lookups++
+	return c.memoryStore.GetMany(ctx, ids)
+}
+
+func TestExpandBatched(t *testing.T) {
+	headings := make([]string, 30)
+	for i := range headings {
+		headings[i] = "Intro"
+	}
+	store := &countingStore{memoryStore: post("aaa", headings...)}
+	options := query.ExpandOptions{Mode: query.ExpandPost, MaxPostChars: 1000, MaxSectionChunks: 12}
+	passages := query.Expand(context.Background(), store, []vectorstore.Result{hit(store.memoryStore, 7, 0.9)}, options)
+	assert.Equal(t, len(passages), 1)
+	assert.Assert(t, strings.HasPrefix(passages[0].Text, "0\n1\n"))
+	assert.Assert(t, strings.HasSuffix(passages[0].Text, "28\n29\n"))
+	// the chunks of the post are fetched at once
+	assert.Equal(t, store.lookups, 1)
+}
+
+func TestExpandOptionsFromEnv(t *testing.T) {
+	t.Setenv("EXPAND_MODE", "post")
+	options, err := query.ExpandOptionsFromEnv()
+	assert.NilError(t, err)
+	assert.Equal(t, options.Mode, query.ExpandPost)
+
+	t.Setenv("EXPAND_MODE", "paragraph")
+	_, err = query.ExpandOptionsFromEnv()
+	assert.ErrorContains(t, err, `unknown expand mode "paragraph"`)
+}
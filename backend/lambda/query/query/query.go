@@ -8,47 +8,166 @@ import (
 	"os"
 	"ragembeddings"
 	"strconv"
+	"sync"
 
 	re "ragembeddings"
 	"ragembeddings/bedrock"
-	"ragembeddings/localstore"
 
-	be "github.com/megaproaktiv/bedrockembedding/titan"
-
-	"github.com/philippgille/chromem-go"
+	"hugoembedding/embedding"
+	"hugoembedding/localstore"
+	"hugoembedding/vectorstore"
 )
 
-var db *chromem.DB
+// store has the documents of the import
+var store vectorstore.VectorStore
+
+// embedder embeds the questions, it must match the embedder of the import
+var embedder embedding.Embedder
+
+// expandOptions configure how the hits are expanded to passages
+var expandOptions ExpandOptions
+
+// dbPath is the chromem database file bundled with the lambda
+const dbPath = "./db.gob"
+
+// sqlitePath is the SQLite database file bundled with the lambda
+const sqlitePath = "./db.sqlite"
+
+// DefaultTopK is the number of documents found for a question
+const DefaultTopK = 5
+
+// MaxTopK limits the top_k of a request
+const MaxTopK = 50
+
+// topK is DefaultTopK or TOP_K
+var topK = DefaultTopK
 
 func init() {
+	if k, err := strconv.Atoi(os.Getenv("TOP_K")); err == nil && k > 0 {
+		topK = min(k, MaxTopK)
+	}
+}
 
-	path := "./db.gob"
+// setupMutex guards the setup of the embedder and the store, see setup
+var setupMutex sync.Mutex
+
+// opened is set by the first successful setup
+var opened bool
+
+// setup opens the embedder and the store for the first question, the
+// following invocations of a warm lambda instance reuse them. A failed
+// setup, e.g. a timeout of Secrets Manager, is tried again by the next
+// question. The tests of the package run without a database.
+func setup() error {
+	setupMutex.Lock()
+	defer setupMutex.Unlock()
+	if opened {
+		return nil
+	}
+	if err := open(); err != nil {
+		return err
+	}
+	opened = true
+	return nil
+}
+
+func open() error {
 	var err error
-	db, err = localstore.Load(path)
+	expandOptions, err = ExpandOptionsFromEnv()
+	if err != nil {
+		return err
+	}
+	cfg := embedding.ConfigFromEnv(embedding.DefaultConfig())
+	cfg.InputType = embedding.InputQuery
+	embedder, err = embedding.New(cfg)
 	if err != nil {
-		panic(err)
+		return err
 	}
+	storeCfg := vectorstore.ConfigFromEnv(vectorstore.DefaultConfig())
+	// DB_PATH and the key of an encrypted database file
+	file := localstore.FileOptions{Path: dbPath}
+	if storeCfg.Kind == vectorstore.KindSQLite {
+		file.Path = sqlitePath
+	}
+	file = localstore.FileOptionsFromEnv(file)
+	if err := file.CheckStore(storeCfg.Kind); err != nil {
+		return err
+	}
+	store, err = openStore(storeCfg, file)
+	return err
 }
-func Query(c context.Context, req re.QueryRequest) re.Response {
+
+// openStore opens the configured vector store
+func openStore(cfg vectorstore.Config, file localstore.FileOptions) (vectorstore.VectorStore, error) {
+	switch cfg.Kind {
+	case vectorstore.KindPgvector:
+		// the pool is created once per lambda instance, warm invocations
+		// reuse its connections
+		databaseURL := cfg.DatabaseURL
+		if databaseURL == "" {
+			var err error
+			databaseURL, err = vectorstore.DatabaseURLFromEnv(context.Background())
+			if err != nil {
+				return nil, err
+			}
+		}
+		postgres, err := vectorstore.ConnectPostgresPool(databaseURL, cfg.Postgres, context.Background())
+		if err != nil {
+			return nil, err
+		}
+		// fails if the table was imported with another embedding model
+		if err := localstore.CheckDBManifest(postgres, embedder, context.Background()); err != nil {
+			postgres.Close()
+			return nil, err
+		}
+		return postgres, nil
+	case vectorstore.KindChromem:
+		// fails if the database was built with another embedding model
+		db, err := localstore.Load(file, embedder, context.Background())
+		if err != nil {
+			return nil, err
+		}
+		return vectorstore.NewChromem(db, nil)
+	case vectorstore.KindSQLite:
+		if err := localstore.CheckManifest(file.Path, embedder); err != nil {
+			return nil, err
+		}
+		// the settings of the file give the encoding of the vectors
+		return vectorstore.OpenSQLite(file.Path, vectorstore.SQLiteOptions{ReadOnly: true}, context.Background())
+	}
+	return nil, fmt.Errorf("unknown vector store %q", cfg.Kind)
+}
+
+func Query(c context.Context, req re.QueryRequest) (re.Response, error) {
 
 	log := re.Logger
+	if err := setup(); err != nil {
+		log.Error("Error opening the vector store", "error", err)
+		return re.Response{}, err
+	}
 
 	question := req.Question
 	log.Info("Question received", "question", question)
 	// log.Println("Category", req.Category)
 	// log.Println("Version", req.Version)
 
-	var myEmbeddingFunc chromem.EmbeddingFunc
-	myEmbeddingFunc = MyEmbeddingFunc
-
-	log.Info("Query collection start")
-	collection := db.GetCollection("knowledge-base", myEmbeddingFunc)
-	res, err := collection.Query(c, question, 5, nil, nil)
+	log.Info("Query collection start", "embedder", embedder.Name())
+	questionEmbedding, err := embedding.Embed(c, embedder, question)
 	if err != nil {
-		panic(err)
+		log.Error("Error embedding the question", "error", err)
+		return re.Response{}, err
 	}
-
-	// rows, err := collection.Query(c, "SELECT id, content,context,link, title  FROM documents ORDER BY embedding <=> $1 LIMIT 10", pgvector.NewVector(embedding))
+	// both stores only compare documents whose metadata matches the filter
+	k := topK
+	if req.TopK > 0 {
+		k = min(req.TopK, MaxTopK)
+	}
+	res, err := store.Query(c, questionEmbedding, k, req.Filter)
+	if err != nil {
+		log.Error("Error querying the vector store", "error", err)
+		return re.Response{}, err
+	}
+	log.Info("Documents found", "count", len(res), "top_k", k, "filter", req.Filter)
 
 	log.Info("Template creation start")
 	promptTemplate, err := os.ReadFile("prompt.tmpl")
@@ -63,33 +182,35 @@ func Query(c context.Context, req re.QueryRequest) re.Response {
 
 	documentExcerpts := ""
 	Documents := make([]ragembeddings.RagDocument, 0)
-	for _, r := range res {
-		idString := r.ID
-		id, err := strconv.Atoi(idString)
-		if err != nil {
-			id = 1
-			log.Error("Wrong ID: ", "id", idString)
-		}
+	passages := Expand(c, store, res, expandOptions)
+	log.Info("Hits expanded", "hits", len(res), "passages", len(passages), "mode", expandOptions.Mode)
+	for _, passage := range passages {
+		// the best hit of the passage is the reference
+		r := passage.Hits[0]
+		id := r.ID
 		content := r.Content
+		image := r.Metadata["image"]
 
-		context := r.Metadata["link"]
-		// link := r.Metadata["link"]
-		// title := r.Metadata["title"]
-
-		log.Debug("Found", "id", id, "content", content[:64])
+		log.Debug("Found", "id", id, "hits", len(passage.Hits), "content", content[:min(64, len(content))])
 		documentExcerpts += preExcerpt
-		documentExcerpts += content + "\n"
+		documentExcerpts += passage.Text
+		if image != "" {
+			documentExcerpts += "Image: " + image + "\n"
+		}
 		documentExcerpts += postExcerpt
 
 		Documents = append(Documents, ragembeddings.RagDocument{
 			Id:      id,
 			Content: content,
-			Context: context,
+			Context: passage.Text,
+			Title:   passage.Title,
+			Link:    passage.Link,
+			Image:   image,
 		})
 	}
 	tmpl, err := template.New("Prompt").Parse(templateStr)
 	if err != nil {
-		log.Error("Error parsing template:", err)
+		log.Error("Error parsing template:", "error", err)
 	}
 	data := ragembeddings.TemplateData{
 		Question: question,
@@ -98,7 +219,7 @@ func Query(c context.Context, req re.QueryRequest) re.Response {
 	var buffer bytes.Buffer
 	err = tmpl.Execute(&buffer, data)
 	if err != nil {
-		log.Error("Error executing template:", err)
+		log.Error("Error executing template:", "error", err)
 	}
 
 	// Extract the string from the buffer
@@ -106,13 +227,10 @@ func Query(c context.Context, req re.QueryRequest) re.Response {
 	prompt := buffer.String()
 	answer := bedrock.Chat(prompt)
 	response := ragembeddings.Response{
+		Version:   ragembeddings.ResponseVersion,
 		Answer:    answer,
 		Documents: Documents,
 	}
 	log.Info("Answer received claude")
-	return response
-}
-func MyEmbeddingFunc(ctx context.Context, text string) ([]float32, error) {
-
-	return be.FetchEmbedding(text)
+	return response, nil
 }
@@ -0,0 +1,33 @@
+package query_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	re "ragembeddings"
+	"ragembeddings/query"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestQuerySetupRetried(t *testing.T) {
+	ctx := context.Background()
+	request := re.QueryRequest{Question: "How do I find EBS boot volumes?"}
+	t.Setenv("EMBEDDING_PROVIDER", "hashing")
+
+	t.Setenv("EXPAND_MODE", "paragraph")
+	_, err := query.Query(ctx, request)
+	assert.ErrorContains(t, err, `unknown expand mode "paragraph"`)
+
+	t.Setenv("EXPAND_MODE", "section")
+	t.Setenv("VECTOR_STORE", "none")
+	_, err = query.Query(ctx, request)
+	assert.ErrorContains(t, err, `unknown vector store "none"`)
+
+	// the next question opens the store again instead of the failed setup
+	t.Setenv("VECTOR_STORE", "sqlite")
+	t.Setenv("DB_PATH", filepath.Join(t.TempDir(), "missing.sqlite"))
+	_, err = query.Query(ctx, request)
+	assert.ErrorContains(t, err, "reading sqlite settings")
+}
@@ -3,15 +3,31 @@ package ragembeddings
 type QueryRequest struct {
 	Question string `json:"question"`
 	License  string `json:"license,omitempty"`
+	// Filter restricts the documents to those whose metadata has all
+	// values, e.g. {"language": "en"}
+	Filter map[string]string `json:"filter,omitempty"`
+	// TopK is the number of documents found, 0 for the default
+	TopK int `json:"top_k,omitempty"`
 }
 
+// RagDocument is a chunk which was used for the answer
 type RagDocument struct {
-	Id      int    `json:"id"`
+	// Id is stable across imports, e.g. "3f2a9c1b7d4e-0007"
+	Id      string `json:"id"`
 	Content string `json:"content"`
+	// Context is the chunk with its neighbours
 	Context string `json:"context"`
+	Title   string `json:"title,omitempty"`
+	Link    string `json:"link,omitempty"`
+	Image   string `json:"image,omitempty"`
 }
 
+// ResponseVersion is incremented with incompatible changes of the Response,
+// version 2 has string document IDs
+const ResponseVersion = 2
+
 type Response struct {
+	Version   int           `json:"version"`
 	Answer    string        `json:"answer"`
 	Documents []RagDocument `json:"documents"`
 }
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log"
 	"query/rag"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -17,6 +18,9 @@ func main() {
 	// Parse command line arguments
 	questionPtr := flag.String("question", "", "The question to ask the Lambda function")
 	verbose := flag.Bool("verbose", false, "Show documents also")
+	topK := flag.Int("top-k", 0, "Number of documents to find, 0 for the default of the Lambda function")
+	filter := filterFlag{}
+	flag.Var(filter, "filter", "Metadata filter key=value, e.g. language=en, may be repeated")
 	flag.Parse()
 
 	if *questionPtr == "" {
@@ -33,8 +37,10 @@ func main() {
 	client := lambda.NewFromConfig(cfg)
 
 	// Define the payload
-	payload := map[string]string{
-		"question": *questionPtr,
+	payload := rag.QueryRequest{
+		Question: *questionPtr,
+		Filter:   filter,
+		TopK:     *topK,
 	}
 
 	// Marshal the payload into JSON
@@ -66,16 +72,44 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to unmarshal response payload, %v", err)
 	}
+	if response.Version != rag.ResponseVersion {
+		log.Printf("response version %d, the cli expects version %d", response.Version, rag.ResponseVersion)
+	}
 
 	fmt.Println("Answer:", response.Answer)
 
 	if *verbose {
-		fmt.Println("\n The following documents were used \n ============\n")
+		fmt.Print("\n The following documents were used \n ============\n\n")
 
 		for _, doc := range response.Documents {
-			fmt.Printf("Document ID: %d\n", doc.Id)
+			fmt.Printf("Document ID: %s\n", doc.Id)
+			if doc.Title != "" {
+				fmt.Printf("Title: %s\n", doc.Title)
+			}
+			if doc.Link != "" {
+				fmt.Printf("Link: %s\n", doc.Link)
+			}
 			fmt.Printf("Content: %s\n", doc.Content)
 			fmt.Printf("Context: %s\n", doc.Context)
+			if doc.Image != "" {
+				fmt.Printf("Image: %s\n", doc.Image)
+			}
 		}
 	}
 }
+
+// filterFlag collects the -filter key=value flags
+type filterFlag map[string]string
+
+func (f filterFlag) String() string {
+	return fmt.Sprint(map[string]string(f))
+}
+
+func (f filterFlag) Set(value string) error {
+	key, val, found := strings.Cut(value, "=")
+	if !found || key == "" {
+		return fmt.Errorf("filter %q is not key=value", value)
+	}
+	f[key] = val
+	return nil
+}
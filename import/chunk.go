@@ -1,7 +1,48 @@
 package hugoembedding
 
+// HeadingSeparator separates the headings in Chunk.HeadingPath
+const HeadingSeparator = " > "
+
+// ChunkKind is the markdown element a chunk was made from
+type ChunkKind string
+
+const (
+	ChunkText  ChunkKind = "text"
+	ChunkList  ChunkKind = "list"
+	ChunkCode  ChunkKind = "code"
+	ChunkTable ChunkKind = "table"
+	ChunkImage ChunkKind = "image"
+)
+
 type Chunk struct {
 	Chunk     *string
 	Context   *string
 	Reference *string
+	// HeadingPath is the path of headings above the chunk,
+	// e.g. "Setup > Prerequisites > IAM"
+	HeadingPath string
+	// Links are the targets of the links in the chunk text,
+	// internal links are site paths, see ResolveLink
+	Links []string
+	Kind  ChunkKind
+	// Image is the path of the image of an image chunk
+	Image string
+	// Language of a code chunk, from the info string of the code block
+	Language string
+	// Description of a code chunk, the paragraph before the code block
+	Description string
+}
+
+// EmbeddingText is the chunk text with the heading path in front,
+// so that a paragraph is found together with its section.
+// Code chunks also get their description.
+func (c Chunk) EmbeddingText() string {
+	text := *c.Chunk
+	if c.Description != "" {
+		text = c.Description + "\n" + text
+	}
+	if c.HeadingPath == "" {
+		return text
+	}
+	return c.HeadingPath + "\n" + text
 }
@@ -0,0 +1,154 @@
+package hugoembedding
+
+import (
+	"regexp"
+	"strings"
+)
+
+// CodeChunkSize is the maximum size of a code chunk in bytes.
+// Larger code blocks are split at declarations or blank lines.
+var CodeChunkSize = 1500
+
+// declarations matches the lines which start a top level declaration
+var declarations = map[string]*regexp.Regexp{
+	"go":     regexp.MustCompile(`^(func|type|var|const|import|package)\b`),
+	"python": regexp.MustCompile(`^(def|async def|class|@|import|from)\b`),
+	"hcl":    regexp.MustCompile(`^(resource|data|module|variable|output|locals|provider|terraform)\b`),
+	"yaml":   regexp.MustCompile(`^(---|[A-Za-z0-9_"'.-]+:)`),
+}
+
+// languageAliases maps info strings to the declaration rules
+var languageAliases = map[string]string{
+	"go":            "go",
+	"golang":        "go",
+	"python":        "python",
+	"py":            "python",
+	"hcl":           "hcl",
+	"hcl-terraform": "hcl",
+	"terraform":     "hcl",
+	"tf":            "hcl",
+	"yaml":          "yaml",
+	"yml":           "yaml",
+}
+
+// codeLanguage returns the language of a fenced code block info string,
+// e.g. "go {linenos=true}" => "go"
+func codeLanguage(info string) string {
+	fields := strings.Fields(info)
+	if len(fields) == 0 {
+		return ""
+	}
+	language := strings.ToLower(strings.Trim(fields[0], "{}."))
+	language, _, _ = strings.Cut(language, "{")
+	return language
+}
+
+// SplitCode splits code into parts which are not larger than size.
+// Parts end before top level declarations of Go, Python, HCL and YAML,
+// for other languages at blank lines. Comments above a declaration stay
+// with the declaration.
+func SplitCode(code string, language string, size int) []string {
+	if len(code) <= size || size <= 0 {
+		return []string{code}
+	}
+	lines := strings.SplitAfter(code, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var blocks [][]string
+	if declaration, ok := declarations[languageAliases[language]]; ok {
+		blocks = splitLines(lines, declarationStarts(lines, declaration))
+	} else {
+		blocks = [][]string{lines}
+	}
+
+	// blocks which are still too large are split at blank lines and
+	// at last between lines
+	var pieces []string
+	for _, block := range blocks {
+		if blockSize(block) <= size {
+			pieces = append(pieces, strings.Join(block, ""))
+			continue
+		}
+		for _, paragraph := range splitLines(block, blankLineStarts(block)) {
+			if blockSize(paragraph) <= size {
+				pieces = append(pieces, strings.Join(paragraph, ""))
+				continue
+			}
+			pieces = append(pieces, paragraph...)
+		}
+	}
+
+	// pack consecutive pieces up to the size
+	var parts []string
+	current := ""
+	for _, piece := range pieces {
+		if current != "" && len(current)+len(piece) > size {
+			parts = append(parts, current)
+			current = ""
+		}
+		current += piece
+	}
+	if strings.TrimSpace(current) != "" {
+		parts = append(parts, current)
+	}
+	return parts
+}
+
+// declarationStarts returns the indices of lines which start a declaration,
+// including the comment lines directly above
+func declarationStarts(lines []string, declaration *regexp.Regexp) []int {
+	var starts []int
+	for i, line := range lines {
+		if i == 0 || !declaration.MatchString(line) {
+			continue
+		}
+		// a python decorator starts the declaration
+		if strings.HasPrefix(lines[i-1], "@") {
+			continue
+		}
+		start := i
+		for start > 0 && isComment(lines[start-1]) {
+			start--
+		}
+		if start > 0 && (len(starts) == 0 || starts[len(starts)-1] < start) {
+			starts = append(starts, start)
+		}
+	}
+	return starts
+}
+
+// blankLineStarts returns the indices of lines which follow a blank line
+func blankLineStarts(lines []string) []int {
+	var starts []int
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i-1]) == "" && strings.TrimSpace(lines[i]) != "" {
+			starts = append(starts, i)
+		}
+	}
+	return starts
+}
+
+func isComment(line string) bool {
+	return strings.HasPrefix(line, "//") || strings.HasPrefix(line, "#")
+}
+
+// splitLines splits the lines before each start index
+func splitLines(lines []string, starts []int) [][]string {
+	var blocks [][]string
+	previous := 0
+	for _, start := range starts {
+		blocks = append(blocks, lines[previous:start])
+		previous = start
+	}
+	return append(blocks, lines[previous:])
+}
+
+func blockSize(lines []string) int {
+	size := 0
+	for _, line := range lines {
+		size += len(line)
+	}
+	return size
+}
@@ -0,0 +1,64 @@
+package hugoembedding_test
+
+import (
+	"hugoembedding"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestSplitCodeGo(t *testing.T) {
+	code := `package main
+
+import "fmt"
+
+// Hello greets
+// the world
+func Hello() {
+	fmt.Println("Hello")
+
+	fmt.Println("World")
+}
+
+func main() {
+	Hello()
+}
+`
+	parts := hugoembedding.SplitCode(code, "go", 80)
+	assert.DeepEqual(t, parts, []string{
+		"package main\n\nimport \"fmt\"\n\n",
+		"// Hello greets\n// the world\nfunc Hello() {\n\tfmt.Println(\"Hello\")\n\n",
+		// small pieces are packed together
+		"\tfmt.Println(\"World\")\n}\n\nfunc main() {\n\tHello()\n}\n",
+	})
+	assert.Equal(t, strings.Join(parts, ""), code)
+}
+
+func TestSplitCodePython(t *testing.T) {
+	code := "import boto3\n\n@handler\ndef first():\n    pass\n\nclass Second:\n    pass\n"
+	parts := hugoembedding.SplitCode(code, "py", 40)
+	assert.DeepEqual(t, parts, []string{
+		"import boto3\n\n",
+		"@handler\ndef first():\n    pass\n\n",
+		"class Second:\n    pass\n",
+	})
+}
+
+func TestSplitCodeSmall(t *testing.T) {
+	code := "aws sts get-caller-identity\n"
+	assert.DeepEqual(t, hugoembedding.SplitCode(code, "bash", 100), []string{code})
+}
+
+func TestParseCodeBlock(t *testing.T) {
+	content := []byte("## CDK\n\nCreate the bucket with CDK:\n\n```ts {linenos=true}\nnew s3.Bucket(this, 'Bucket');\n```\n")
+	chunks, err := hugoembedding.Parse(content, hugoembedding.DefaultConfig())
+	assert.NilError(t, err)
+	assert.Equal(t, len(*chunks), 2)
+
+	code := (*chunks)[1]
+	assert.Equal(t, code.Kind, hugoembedding.ChunkCode)
+	assert.Equal(t, code.Language, "ts")
+	assert.Equal(t, code.Description, "Create the bucket with CDK:")
+	assert.Equal(t, code.EmbeddingText(), "CDK\nCreate the bucket with CDK:\nnew s3.Bucket(this, 'Bucket');\n")
+}
@@ -2,29 +2,62 @@ package hugoembedding
 
 // CombineChunks, so that the lenght of the combined chunks
 // is less than the size parameter
+//
+// Deprecated: use PackChunks, which sizes by tokens and keeps code apart.
+// Chunks are only combined within the same heading path,
+// image chunks are never combined.
 func CompressChunks(chunks *[]Chunk, size int) (*[]Chunk, error) {
 	// Range an chunks and split it to the size
 	startChunk := true
 	endFlag := false
 	combinedChunk := ""
+	headingPath := ""
+	var links []string
+	var kind ChunkKind
+	image := ""
+	language := ""
+	description := ""
 	resultChunks := []Chunk{}
 	for i, chunk := range *chunks {
 
 		if startChunk {
 			combinedChunk = *chunk.Chunk
+			headingPath = chunk.HeadingPath
+			kind = chunk.Kind
+			image = chunk.Image
+			language = ""
+			description = ""
+			links = nil
 			startChunk = false
 		} else {
 			combinedChunk = combinedChunk + *chunk.Chunk
 		}
-		if i == len(*chunks)-1 {
+		links = append(links, chunk.Links...)
+		if language == "" && chunk.Language != "" {
+			language = chunk.Language
+			description = chunk.Description
+		}
+		if i == len(*chunks)-1 ||
+			(*chunks)[i+1].HeadingPath != headingPath ||
+			(*chunks)[i+1].Kind == ChunkImage ||
+			kind == ChunkImage {
 			endFlag = true
 		}
 
 		if len(combinedChunk) > size || endFlag {
 			startChunk = true
+			endFlag = false
 			// Append the combined chunk to resultChunks
 			line := combinedChunk
-			resultChunks = append(resultChunks, Chunk{Chunk: &line})
+			resultChunks = append(resultChunks, Chunk{
+				Chunk:       &line,
+				HeadingPath: headingPath,
+				Links:       links,
+				Kind:        kind,
+				Image:       image,
+				Language:    language,
+				Description: description,
+			})
 			// Reset the combinedChunk
 			combinedChunk = ""
 
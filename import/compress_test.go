@@ -59,7 +59,21 @@ func TestCompressLists(t *testing.T) {
 	path := "testdata/how-to-use-pow/index.md"
 	markdownFileContent, err := os.ReadFile(path)
 	assert.NilError(t, err)
-	chunks, err := hugoembedding.Parse(markdownFileContent)
+	chunks, err := hugoembedding.Parse(markdownFileContent, hugoembedding.DefaultConfig())
 	assert.NilError(t, err)
-	assert.Equal(t, len(*chunks), 4)
+	assert.Equal(t, len(*chunks), 9)
+}
+
+func TestCompressChunksKeepsSections(t *testing.T) {
+	input := []hugoembedding.Chunk{
+		{Chunk: aws.String("Setup text.\n"), HeadingPath: "Setup"},
+		{Chunk: aws.String("More setup.\n"), HeadingPath: "Setup"},
+		{Chunk: aws.String("You need a role.\n"), HeadingPath: "Setup > IAM"},
+	}
+	result, err := hugoembedding.CompressChunks(&input, 300)
+	assert.NilError(t, err)
+	assert.Equal(t, len(*result), 2)
+	assert.Equal(t, *(*result)[0].Chunk, "Setup text.\nMore setup.\n")
+	assert.Equal(t, (*result)[0].HeadingPath, "Setup")
+	assert.Equal(t, (*result)[1].HeadingPath, "Setup > IAM")
 }
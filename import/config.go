@@ -0,0 +1,59 @@
+package hugoembedding
+
+import (
+	"context"
+	"fmt"
+	"hugoembedding/embedding"
+)
+
+// Chunking strategies
+const (
+	// ChunkerStructural packs the markdown elements by size
+	ChunkerStructural = "structural"
+	// ChunkerSemantic combines the markdown elements by similarity
+	ChunkerSemantic = "semantic"
+)
+
+// Config holds the settings of an import run
+type Config struct {
+	// BaseURL of the site, e.g. https://example.com/, the links of the
+	// documents are resolved against it. Empty keeps site paths.
+	BaseURL string
+	// Chunker is the chunking strategy, ChunkerStructural or ChunkerSemantic
+	Chunker string
+	// Pack sizes the chunks of the structural chunker
+	Pack PackOptions
+	// Semantic configures the semantic chunker
+	Semantic SemanticOptions
+	// Shortcodes replaces the Hugo shortcodes before parsing, change its
+	// rules to handle custom shortcodes
+	Shortcodes *ShortcodeProcessor
+	// Embedding selects the embedding provider and model
+	Embedding embedding.Config
+	// Scheduler limits and retries the embedding requests
+	Scheduler embedding.SchedulerOptions
+}
+
+// DefaultConfig returns the settings which are used without configuration
+func DefaultConfig() *Config {
+	return &Config{
+		Chunker:    ChunkerStructural,
+		Pack:       DefaultPackOptions,
+		Semantic:   DefaultSemanticOptions,
+		Shortcodes: NewShortcodeProcessor(),
+		Embedding:  embedding.DefaultConfig(),
+		Scheduler:  embedding.DefaultSchedulerOptions,
+	}
+}
+
+// ChunkDocument combines the parsed chunks of a document with the configured
+// strategy. The semantic chunker uses embed for the similarity.
+func ChunkDocument(chunks *[]Chunk, cfg *Config, embed EmbedFunc, ctx context.Context) (*[]Chunk, error) {
+	switch cfg.Chunker {
+	case ChunkerStructural, "":
+		return PackChunks(chunks, cfg.Pack)
+	case ChunkerSemantic:
+		return SemanticChunks(ctx, chunks, embed, cfg.Semantic)
+	}
+	return nil, fmt.Errorf("unknown chunker %q", cfg.Chunker)
+}
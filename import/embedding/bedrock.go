@@ -0,0 +1,229 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+)
+
+const defaultRegion = "eu-central-1"
+
+// Bedrock model ids, see
+// https://docs.aws.amazon.com/bedrock/latest/userguide/model-ids-arns.html
+const (
+	TitanV1Model = "amazon.titan-embed-text-v1"
+	TitanV2Model = "amazon.titan-embed-text-v2:0"
+	CohereModel  = "cohere.embed-multilingual-v3"
+)
+
+// BedrockClient is the part of the bedrockruntime client the embedders use
+type BedrockClient interface {
+	InvokeModel(ctx context.Context, params *bedrockruntime.InvokeModelInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelOutput, error)
+}
+
+// Client is used by the bedrock embedders. It is created on first use
+// with the region from AWS_REGION, tests may replace it.
+var Client BedrockClient
+
+var clientOnce sync.Once
+
+// clientErr is the error of loading the aws config, it fails all
+// requests of the process
+var clientErr error
+
+func bedrockClient(ctx context.Context) (BedrockClient, error) {
+	clientOnce.Do(func() {
+		if Client != nil {
+			return
+		}
+		region := os.Getenv("AWS_REGION")
+		if region == "" {
+			region = defaultRegion
+		}
+		cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+		if err != nil {
+			clientErr = fmt.Errorf("loading aws config: %w", err)
+			return
+		}
+		Client = bedrockruntime.NewFromConfig(cfg)
+	})
+	if clientErr != nil {
+		return nil, clientErr
+	}
+	return Client, nil
+}
+
+// invokeModel sends the request as JSON and decodes the JSON response
+func invokeModel(ctx context.Context, model string, request interface{}, response interface{}) error {
+	client, err := bedrockClient(ctx)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	output, err := client.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
+		Body:        payload,
+		ModelId:     aws.String(model),
+		ContentType: aws.String("application/json"),
+		Accept:      aws.String("application/json"),
+	})
+	if err != nil {
+		return fmt.Errorf("invoking %v: %w", model, err)
+	}
+	if err := json.Unmarshal(output.Body, response); err != nil {
+		return fmt.Errorf("decoding %v response: %w", model, err)
+	}
+	return nil
+}
+
+// Titan embeds one text per call
+type Titan struct {
+	model      string
+	dimensions int
+	normalize  bool
+	maxInput   int
+	v2         bool
+}
+
+type titanRequest struct {
+	InputText  string `json:"inputText"`
+	Dimensions int    `json:"dimensions,omitempty"`
+	Normalize  *bool  `json:"normalize,omitempty"`
+}
+
+type titanResponse struct {
+	Embedding           []float64 `json:"embedding"`
+	InputTextTokenCount int       `json:"inputTextTokenCount"`
+}
+
+// NewTitanV1 returns the Titan text embedding v1 with 1536 dimensions
+func NewTitanV1(model string) *Titan {
+	if model == "" {
+		model = TitanV1Model
+	}
+	// 8k tokens, the characters are a conservative estimate
+	return &Titan{model: model, dimensions: 1536, maxInput: 25000}
+}
+
+// NewTitanV2 returns the Titan text embedding v2 with 256, 512 or 1024
+// dimensions, 0 is the default of 1024
+func NewTitanV2(model string, dimensions int, normalize bool) (*Titan, error) {
+	if model == "" {
+		model = TitanV2Model
+	}
+	switch dimensions {
+	case 0:
+		dimensions = 1024
+	case 256, 512, 1024:
+	default:
+		return nil, fmt.Errorf("titan v2 supports 256, 512 or 1024 dimensions, not %d", dimensions)
+	}
+	return &Titan{model: model, dimensions: dimensions, normalize: normalize, maxInput: 50000, v2: true}, nil
+}
+
+// Name includes the options of Titan v2, they change the vectors
+func (t *Titan) Name() string {
+	if t.v2 {
+		return fmt.Sprintf("bedrock/%v?dimensions=%d&normalize=%v", t.model, t.dimensions, t.normalize)
+	}
+	return "bedrock/" + t.model
+}
+
+func (t *Titan) Dimensions() int {
+	return t.dimensions
+}
+
+func (t *Titan) MaxInput() int {
+	return t.maxInput
+}
+
+func (t *Titan) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, 0, len(texts))
+	for _, text := range texts {
+		request := titanRequest{InputText: truncate(text, t.maxInput)}
+		if t.v2 {
+			normalize := t.normalize
+			request.Dimensions = t.dimensions
+			request.Normalize = &normalize
+		}
+		var response titanResponse
+		if err := invokeModel(ctx, t.model, request, &response); err != nil {
+			return nil, err
+		}
+		embeddings = append(embeddings, toFloat32(response.Embedding))
+	}
+	return embeddings, nil
+}
+
+// Cohere embeds up to 96 texts per call with Cohere Embed v3 on Bedrock
+type Cohere struct {
+	model     string
+	inputType string
+}
+
+// cohereBatchSize is the maximum number of texts per request
+const cohereBatchSize = 96
+
+type cohereRequest struct {
+	Texts     []string `json:"texts"`
+	InputType string   `json:"input_type"`
+	Truncate  string   `json:"truncate"`
+}
+
+type cohereResponse struct {
+	Embeddings [][]float64 `json:"embeddings"`
+}
+
+// NewCohere returns Cohere Embed with 1024 dimensions. The input type is
+// InputDocument for the import and InputQuery for the questions.
+func NewCohere(model string, inputType string) *Cohere {
+	if model == "" {
+		model = CohereModel
+	}
+	if inputType == "" {
+		inputType = InputDocument
+	}
+	return &Cohere{model: model, inputType: inputType}
+}
+
+func (c *Cohere) Name() string {
+	return "bedrock/" + c.model
+}
+
+func (c *Cohere) Dimensions() int {
+	return 1024
+}
+
+func (c *Cohere) MaxInput() int {
+	return 2048
+}
+
+func (c *Cohere) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += cohereBatchSize {
+		end := min(start+cohereBatchSize, len(texts))
+		request := cohereRequest{InputType: c.inputType, Truncate: "END"}
+		for _, text := range texts[start:end] {
+			request.Texts = append(request.Texts, truncate(text, c.MaxInput()))
+		}
+		var response cohereResponse
+		if err := invokeModel(ctx, c.model, request, &response); err != nil {
+			return nil, err
+		}
+		if len(response.Embeddings) != end-start {
+			return nil, fmt.Errorf("%v returned %d embeddings for %d texts", c.Name(), len(response.Embeddings), end-start)
+		}
+		for _, embedding := range response.Embeddings {
+			embeddings = append(embeddings, toFloat32(embedding))
+		}
+	}
+	return embeddings, nil
+}
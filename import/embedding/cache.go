@@ -0,0 +1,200 @@
+package embedding
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// cacheExtension is the file extension of cached embeddings
+const cacheExtension = ".f32"
+
+// Cache stores the embeddings of an embedder on disk. The key is the
+// model and the hash of the normalized text, so unchanged chunks are
+// not embedded again.
+type Cache struct {
+	embedder Embedder
+	dir      string
+
+	mu    sync.Mutex
+	stats CacheStats
+	used  map[string]bool
+}
+
+// CacheStats counts the cache lookups
+type CacheStats struct {
+	Hits   int
+	Misses int
+}
+
+// NewCache wraps the embedder with a cache in the directory
+func NewCache(embedder Embedder, dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating embedding cache: %w", err)
+	}
+	return &Cache{embedder: embedder, dir: dir, used: map[string]bool{}}, nil
+}
+
+func (c *Cache) Name() string {
+	return c.embedder.Name()
+}
+
+func (c *Cache) Dimensions() int {
+	return c.embedder.Dimensions()
+}
+
+func (c *Cache) MaxInput() int {
+	return c.embedder.MaxInput()
+}
+
+// EmbedBatch reads the cached embeddings and embeds the missing texts
+// in one batch
+func (c *Cache) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	keys := make([]string, len(texts))
+	var missing []int
+	for i, text := range texts {
+		keys[i] = c.key(text)
+		embedding, err := c.read(keys[i])
+		if err != nil {
+			missing = append(missing, i)
+			continue
+		}
+		embeddings[i] = embedding
+	}
+
+	c.mu.Lock()
+	c.stats.Hits += len(texts) - len(missing)
+	c.stats.Misses += len(missing)
+	for _, key := range keys {
+		c.used[key] = true
+	}
+	c.mu.Unlock()
+
+	if len(missing) == 0 {
+		return embeddings, nil
+	}
+	missingTexts := make([]string, len(missing))
+	for j, i := range missing {
+		missingTexts[j] = texts[i]
+	}
+	embedded, err := c.embedder.EmbedBatch(ctx, missingTexts)
+	// the embeddings of a partly failed batch are cached
+	var batchError *BatchError
+	if err != nil && !errors.As(err, &batchError) {
+		return nil, err
+	}
+	for j, i := range missing {
+		if embedded[j] == nil {
+			continue
+		}
+		embeddings[i] = embedded[j]
+		if err := c.write(keys[i], embedded[j]); err != nil {
+			return nil, err
+		}
+	}
+	if batchError != nil {
+		failures := make([]Failure, len(batchError.Failures))
+		for j, failure := range batchError.Failures {
+			failure.Index = missing[failure.Index]
+			failures[j] = failure
+		}
+		return embeddings, &BatchError{Failures: failures}
+	}
+	return embeddings, nil
+}
+
+// Stats returns the hits and misses since the cache was created
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Prune removes all entries which were not used since the cache was
+// created, call it after a complete import. It returns the number of
+// removed entries.
+func (c *Cache) Prune() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	removed := 0
+	err := filepath.WalkDir(c.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || filepath.Ext(path) != cacheExtension {
+			return nil
+		}
+		if c.used[strings.TrimSuffix(entry.Name(), cacheExtension)] {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
+
+// key hashes the model and the text with collapsed whitespace
+func (c *Cache) key(text string) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%v\x00", c.embedder.Name())
+	hash.Write([]byte(strings.Join(strings.Fields(text), " ")))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// path spreads the entries over subdirectories
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+cacheExtension)
+}
+
+func (c *Cache) read(key string) ([]float32, error) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 || len(data)%4 != 0 {
+		return nil, errors.New("corrupt cache entry")
+	}
+	embedding := make([]float32, len(data)/4)
+	for i := range embedding {
+		embedding[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+	}
+	return embedding, nil
+}
+
+// write stores the entry with a rename, so a crash leaves no partial entry
+func (c *Cache) write(key string, embedding []float32) error {
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data := make([]byte, len(embedding)*4)
+	for i, v := range embedding {
+		binary.LittleEndian.PutUint32(data[i*4:], math.Float32bits(v))
+	}
+	temp, err := os.CreateTemp(filepath.Dir(path), key+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = temp.Write(data)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
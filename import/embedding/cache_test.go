@@ -0,0 +1,68 @@
+package embedding_test
+
+import (
+	"context"
+	"hugoembedding/embedding"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+// countingEmbedder counts the embedded texts
+type countingEmbedder struct {
+	embedding.Embedder
+	texts int
+}
+
+func (c *countingEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	c.texts += len(texts)
+	return c.Embedder.EmbedBatch(ctx, texts)
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.TODO()
+	dir := t.TempDir()
+	hashing, err := embedding.NewHashing(32)
+	assert.NilError(t, err)
+	counting := &countingEmbedder{Embedder: hashing}
+
+	cache, err := embedding.NewCache(counting, dir)
+	assert.NilError(t, err)
+	first, err := cache.EmbedBatch(ctx, []string{"Lambda function", "Bedrock model"})
+	assert.NilError(t, err)
+	assert.Equal(t, counting.texts, 2)
+	assert.Equal(t, cache.Stats(), embedding.CacheStats{Hits: 0, Misses: 2})
+
+	// a second run with the same content makes no embedding calls,
+	// whitespace changes are ignored
+	counting.texts = 0
+	cache, err = embedding.NewCache(counting, dir)
+	assert.NilError(t, err)
+	second, err := cache.EmbedBatch(ctx, []string{"Lambda  function\n", "Postgres"})
+	assert.NilError(t, err)
+	assert.Equal(t, counting.texts, 1)
+	assert.Equal(t, cache.Stats(), embedding.CacheStats{Hits: 1, Misses: 1})
+	assert.DeepEqual(t, second[0], first[0])
+	assert.Equal(t, cache.Name(), hashing.Name())
+
+	// the entry of "Bedrock model" was not used in this run
+	removed, err := cache.Prune()
+	assert.NilError(t, err)
+	assert.Equal(t, removed, 1)
+
+	counting.texts = 0
+	cache, err = embedding.NewCache(counting, dir)
+	assert.NilError(t, err)
+	_, err = cache.EmbedBatch(ctx, []string{"Lambda function", "Postgres", "Bedrock model"})
+	assert.NilError(t, err)
+	assert.Equal(t, counting.texts, 1)
+
+	// another model does not see the entries
+	other, err := embedding.NewHashing(16)
+	assert.NilError(t, err)
+	cache, err = embedding.NewCache(other, dir)
+	assert.NilError(t, err)
+	_, err = embedding.Embed(ctx, cache, "Lambda function")
+	assert.NilError(t, err)
+	assert.Equal(t, cache.Stats().Misses, 1)
+}
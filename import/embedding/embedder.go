@@ -0,0 +1,157 @@
+// Package embedding creates the embeddings of chunks and questions with
+// different providers. The same provider and model must be used for the
+// import and for the query.
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Embedder creates embeddings with one model
+type Embedder interface {
+	// Name identifies provider and model, e.g. "bedrock/amazon.titan-embed-text-v1"
+	Name() string
+	// Dimensions of the embedding vectors, 0 if not known before the first call
+	Dimensions() int
+	// MaxInput is the maximum length of a text in characters, longer
+	// texts are truncated
+	MaxInput() int
+	// EmbedBatch returns one embedding per text in the same order
+	EmbedBatch(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Providers
+const (
+	ProviderTitanV1 = "titan-v1"
+	ProviderTitanV2 = "titan-v2"
+	ProviderCohere  = "cohere"
+	ProviderOpenAI  = "openai"
+	ProviderOllama  = "ollama"
+	ProviderHashing = "hashing"
+)
+
+// Input types tell asymmetric models like Cohere Embed what is embedded
+const (
+	InputDocument = "search_document"
+	InputQuery    = "search_query"
+)
+
+// Config selects and configures the embedding provider
+type Config struct {
+	// Provider is one of the Provider constants
+	Provider string
+	// Model overwrites the default model of the provider
+	Model string
+	// Dimensions of Titan v2 (256, 512 or 1024), OpenAI v3 models and
+	// the hashing embedder
+	Dimensions int
+	// Normalize lets Titan v2 return unit vectors
+	Normalize bool
+	// Endpoint is the base URL of an OpenAI compatible API
+	Endpoint string
+	// APIKey for the OpenAI compatible API
+	APIKey string
+	// InputType is InputDocument for the import and InputQuery for questions
+	InputType string
+}
+
+// DefaultConfig is Titan v1, which the existing databases are built with
+func DefaultConfig() Config {
+	return Config{
+		Provider:  ProviderTitanV1,
+		Normalize: true,
+		InputType: InputDocument,
+	}
+}
+
+// ConfigFromEnv reads the configuration from the environment:
+//
+//	EMBEDDING_PROVIDER    titan-v1, titan-v2, cohere, openai, ollama or hashing
+//	EMBEDDING_MODEL       model id
+//	EMBEDDING_DIMENSIONS  vector size
+//	EMBEDDING_NORMALIZE   true or false
+//	EMBEDDING_ENDPOINT    base URL of an OpenAI compatible API
+//	EMBEDDING_API_KEY     API key, OPENAI_API_KEY is used as fallback
+func ConfigFromEnv(cfg Config) Config {
+	if provider := os.Getenv("EMBEDDING_PROVIDER"); provider != "" {
+		cfg.Provider = provider
+	}
+	if model := os.Getenv("EMBEDDING_MODEL"); model != "" {
+		cfg.Model = model
+	}
+	if dimensions, err := strconv.Atoi(os.Getenv("EMBEDDING_DIMENSIONS")); err == nil {
+		cfg.Dimensions = dimensions
+	}
+	if normalize, err := strconv.ParseBool(os.Getenv("EMBEDDING_NORMALIZE")); err == nil {
+		cfg.Normalize = normalize
+	}
+	if endpoint := os.Getenv("EMBEDDING_ENDPOINT"); endpoint != "" {
+		cfg.Endpoint = endpoint
+	}
+	cfg.APIKey = os.Getenv("EMBEDDING_API_KEY")
+	if cfg.APIKey == "" {
+		cfg.APIKey = os.Getenv("OPENAI_API_KEY")
+	}
+	return cfg
+}
+
+// New creates the embedder of the configured provider
+func New(cfg Config) (Embedder, error) {
+	switch cfg.Provider {
+	case ProviderTitanV1, "":
+		return NewTitanV1(cfg.Model), nil
+	case ProviderTitanV2:
+		return NewTitanV2(cfg.Model, cfg.Dimensions, cfg.Normalize)
+	case ProviderCohere:
+		return NewCohere(cfg.Model, cfg.InputType), nil
+	case ProviderOpenAI:
+		return NewOpenAI(cfg.Endpoint, cfg.APIKey, cfg.Model, cfg.Dimensions), nil
+	case ProviderOllama:
+		return NewOllama(cfg.Endpoint, cfg.APIKey, cfg.Model, cfg.Dimensions), nil
+	case ProviderHashing:
+		return NewHashing(cfg.Dimensions)
+	}
+	return nil, fmt.Errorf("unknown embedding provider %q", cfg.Provider)
+}
+
+// Embed returns the embedding of a single text
+func Embed(ctx context.Context, embedder Embedder, text string) ([]float32, error) {
+	embeddings, err := embedder.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddings) != 1 {
+		return nil, fmt.Errorf("%v returned %d embeddings for one text", embedder.Name(), len(embeddings))
+	}
+	return embeddings[0], nil
+}
+
+// Func adapts the embedder to the function type of chromem and the
+// semantic chunker
+func Func(embedder Embedder) func(ctx context.Context, text string) ([]float32, error) {
+	return func(ctx context.Context, text string) ([]float32, error) {
+		return Embed(ctx, embedder, text)
+	}
+}
+
+// truncate shortens the text to max characters, 0 is unlimited
+func truncate(text string, max int) string {
+	if max <= 0 || utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:max])
+}
+
+// toFloat32 converts the JSON numbers of the providers
+func toFloat32(values []float64) []float32 {
+	embedding := make([]float32, len(values))
+	for i, value := range values {
+		embedding[i] = float32(value)
+	}
+	return embedding
+}
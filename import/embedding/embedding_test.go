@@ -0,0 +1,194 @@
+package embedding_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"hugoembedding/embedding"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"gotest.tools/v3/assert"
+)
+
+// fakeBedrock answers with an embedding of the request size
+type fakeBedrock struct {
+	requests []map[string]interface{}
+}
+
+func (f *fakeBedrock) InvokeModel(ctx context.Context, params *bedrockruntime.InvokeModelInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelOutput, error) {
+	request := map[string]interface{}{}
+	if err := json.Unmarshal(params.Body, &request); err != nil {
+		return nil, err
+	}
+	request["model"] = *params.ModelId
+	f.requests = append(f.requests, request)
+
+	var body []byte
+	if texts, ok := request["texts"].([]interface{}); ok {
+		embeddings := make([][]float64, len(texts))
+		for i := range texts {
+			embeddings[i] = []float64{float64(i), 1}
+		}
+		body, _ = json.Marshal(map[string]interface{}{"embeddings": embeddings})
+	} else {
+		body, _ = json.Marshal(map[string]interface{}{"embedding": []float64{0.5, 0.5}})
+	}
+	return &bedrockruntime.InvokeModelOutput{Body: body}, nil
+}
+
+func TestTitan(t *testing.T) {
+	fake := &fakeBedrock{}
+	embedding.Client = fake
+	ctx := context.TODO()
+
+	v1, err := embedding.New(embedding.DefaultConfig())
+	assert.NilError(t, err)
+	assert.Equal(t, v1.Name(), "bedrock/amazon.titan-embed-text-v1")
+	assert.Equal(t, v1.Dimensions(), 1536)
+	embeddings, err := v1.EmbedBatch(ctx, []string{"a", "b"})
+	assert.NilError(t, err)
+	assert.Equal(t, len(embeddings), 2)
+	assert.DeepEqual(t, fake.requests[0], map[string]interface{}{
+		"inputText": "a",
+		"model":     "amazon.titan-embed-text-v1",
+	})
+
+	v2, err := embedding.New(embedding.Config{Provider: embedding.ProviderTitanV2, Dimensions: 256, Normalize: true})
+	assert.NilError(t, err)
+	assert.Equal(t, v2.Dimensions(), 256)
+	_, err = embedding.Embed(ctx, v2, "c")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, fake.requests[2], map[string]interface{}{
+		"inputText":  "c",
+		"dimensions": float64(256),
+		"normalize":  true,
+		"model":      "amazon.titan-embed-text-v2:0",
+	})
+
+	_, err = embedding.New(embedding.Config{Provider: embedding.ProviderTitanV2, Dimensions: 300})
+	assert.ErrorContains(t, err, "256, 512 or 1024")
+}
+
+func TestCohere(t *testing.T) {
+	fake := &fakeBedrock{}
+	embedding.Client = fake
+
+	cohere, err := embedding.New(embedding.Config{Provider: embedding.ProviderCohere, InputType: embedding.InputQuery})
+	assert.NilError(t, err)
+	texts := make([]string, 100)
+	for i := range texts {
+		texts[i] = "text"
+	}
+	embeddings, err := cohere.EmbedBatch(context.TODO(), texts)
+	assert.NilError(t, err)
+	assert.Equal(t, len(embeddings), 100)
+	// 96 texts per request
+	assert.Equal(t, len(fake.requests), 2)
+	assert.Equal(t, fake.requests[0]["input_type"], embedding.InputQuery)
+	assert.DeepEqual(t, embeddings[97], []float32{1, 1})
+}
+
+func TestOpenAI(t *testing.T) {
+	var request map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.URL.Path, "/v1/embeddings")
+		assert.Equal(t, r.Header.Get("Authorization"), "Bearer secret")
+		assert.NilError(t, json.NewDecoder(r.Body).Decode(&request))
+		// answer in reverse order
+		w.Write([]byte(`{"data":[{"index":1,"embedding":[0,1,0]},{"index":0,"embedding":[1,0,0]}]}`))
+	}))
+	defer server.Close()
+
+	openai, err := embedding.New(embedding.Config{
+		Provider: embedding.ProviderOpenAI,
+		Endpoint: server.URL + "/v1/",
+		APIKey:   "secret",
+		Model:    "nomic-embed-text",
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, openai.Dimensions(), 0)
+	embeddings, err := openai.EmbedBatch(context.TODO(), []string{"first", "second"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, embeddings, [][]float32{{1, 0, 0}, {0, 1, 0}})
+	assert.Equal(t, openai.Dimensions(), 3)
+	assert.Equal(t, request["model"], "nomic-embed-text")
+	_, hasDimensions := request["dimensions"]
+	assert.Assert(t, !hasDimensions)
+}
+
+func TestOpenAIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":{"message":"rate limit"}}`))
+	}))
+	defer server.Close()
+
+	openai := embedding.NewOpenAI(server.URL, "", "", 0)
+	_, err := embedding.Embed(context.TODO(), openai, "text")
+	var httpError *embedding.HTTPError
+	assert.Assert(t, errors.As(err, &httpError))
+	assert.Equal(t, httpError.StatusCode, http.StatusTooManyRequests)
+	assert.ErrorContains(t, err, "rate limit")
+}
+
+func TestOllama(t *testing.T) {
+	var request map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NilError(t, json.NewDecoder(r.Body).Decode(&request))
+		w.Write([]byte(`{"data":[{"index":0,"embedding":[1,0]}]}`))
+	}))
+	defer server.Close()
+
+	ollama, err := embedding.New(embedding.Config{Provider: embedding.ProviderOllama, Endpoint: server.URL})
+	assert.NilError(t, err)
+	_, err = embedding.Embed(context.TODO(), ollama, "text")
+	assert.NilError(t, err)
+	assert.Equal(t, request["model"], embedding.DefaultOllamaModel)
+	assert.Equal(t, ollama.Name(), "ollama/nomic-embed-text")
+
+	// the same model of another provider has another name
+	openai, err := embedding.New(embedding.Config{Provider: embedding.ProviderOpenAI, Model: "nomic-embed-text", Dimensions: 256})
+	assert.NilError(t, err)
+	assert.Equal(t, openai.Name(), "openai/nomic-embed-text?dimensions=256")
+}
+
+func TestNewUnknownProvider(t *testing.T) {
+	_, err := embedding.New(embedding.Config{Provider: "word2vec"})
+	assert.ErrorContains(t, err, "unknown embedding provider")
+}
+
+func TestHashing(t *testing.T) {
+	hashing, err := embedding.New(embedding.Config{Provider: embedding.ProviderHashing, Dimensions: 64})
+	assert.NilError(t, err)
+	assert.Equal(t, hashing.Name(), "local/hashing-64")
+
+	embeddings, err := hashing.EmbedBatch(context.TODO(), []string{
+		"Deploy a Lambda function with SAM",
+		"deploy the lambda function, with sam!",
+		"Kafka schema evolution in MSK",
+		"",
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, len(embeddings[0]), 64)
+
+	// deterministic
+	again, err := embedding.Embed(context.TODO(), hashing, "Deploy a Lambda function with SAM")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, again, embeddings[0])
+
+	dot := func(a, b []float32) float32 {
+		var sum float32
+		for i := range a {
+			sum += a[i] * b[i]
+		}
+		return sum
+	}
+	assert.Assert(t, math.Abs(float64(dot(embeddings[0], embeddings[0]))-1) < 1e-5)
+	assert.Assert(t, dot(embeddings[0], embeddings[1]) > dot(embeddings[0], embeddings[2]))
+	// an empty text has a zero vector
+	assert.Equal(t, dot(embeddings[3], embeddings[3]), float32(0))
+}
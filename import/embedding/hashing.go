@@ -0,0 +1,105 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// DefaultHashingDimensions is the vector size of the hashing embedder
+const DefaultHashingDimensions = 512
+
+// Hashing is a deterministic embedder which needs no network. Words and
+// word pairs of the normalized text are hashed into the vector (feature
+// hashing), so texts with common words are similar. It is meant for
+// tests and offline development, not for semantic search quality.
+type Hashing struct {
+	dimensions int
+}
+
+// NewHashing returns a hashing embedder, 0 dimensions is the default
+func NewHashing(dimensions int) (*Hashing, error) {
+	if dimensions == 0 {
+		dimensions = DefaultHashingDimensions
+	}
+	if dimensions < 0 {
+		return nil, fmt.Errorf("hashing embedder needs positive dimensions, not %d", dimensions)
+	}
+	return &Hashing{dimensions: dimensions}, nil
+}
+
+func (h *Hashing) Name() string {
+	return fmt.Sprintf("local/hashing-%d", h.dimensions)
+}
+
+func (h *Hashing) Dimensions() int {
+	return h.dimensions
+}
+
+func (h *Hashing) MaxInput() int {
+	return 0
+}
+
+func (h *Hashing) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embeddings[i] = h.embed(text)
+	}
+	return embeddings, nil
+}
+
+func (h *Hashing) embed(text string) []float32 {
+	counts := map[string]float64{}
+	words := tokenize(text)
+	for i, word := range words {
+		counts[word]++
+		if i > 0 {
+			counts[words[i-1]+" "+word] += 0.5
+		}
+	}
+
+	vector := make([]float64, h.dimensions)
+	for feature, count := range counts {
+		hash := fnv.New64a()
+		hash.Write([]byte(feature))
+		sum := hash.Sum64()
+		// the sign bit keeps collisions from adding up
+		sign := 1.0
+		if sum>>63 == 1 {
+			sign = -1.0
+		}
+		// frequent words should not dominate the vector
+		vector[sum%uint64(h.dimensions)] += sign * (1 + math.Log(count+1))
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += v * v
+	}
+	norm = math.Sqrt(norm)
+	embedding := make([]float32, h.dimensions)
+	for i, v := range vector {
+		if norm > 0 {
+			embedding[i] = float32(v / norm)
+		}
+	}
+	return embedding
+}
+
+// tokenize splits the lower case text into words of letters and digits,
+// words of one character are dropped
+func tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	words := fields[:0]
+	for _, field := range fields {
+		if len([]rune(field)) > 1 {
+			words = append(words, field)
+		}
+	}
+	return words
+}
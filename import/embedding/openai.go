@@ -0,0 +1,193 @@
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Defaults of the OpenAI compatible APIs
+const (
+	DefaultOpenAIEndpoint = "https://api.openai.com/v1"
+	DefaultOllamaEndpoint = "http://localhost:11434/v1"
+	DefaultOpenAIModel    = "text-embedding-3-small"
+	DefaultOllamaModel    = "nomic-embed-text"
+)
+
+// openAIBatchSize is the number of texts per request
+const openAIBatchSize = 64
+
+// OpenAI calls the /embeddings endpoint of the OpenAI API or of a
+// compatible server like Ollama
+type OpenAI struct {
+	// provider is the first part of the name, ProviderOpenAI or ProviderOllama
+	provider string
+	endpoint string
+	apiKey   string
+	model    string
+	// HTTPClient is used for the requests
+	HTTPClient *http.Client
+
+	mu         sync.Mutex
+	dimensions int
+	// requestDimensions is sent to the API, only v3 models support it
+	requestDimensions int
+}
+
+type openAIRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions,omitempty"`
+}
+
+type openAIResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float64 `json:"embedding"`
+	} `json:"data"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// NewOpenAI returns an embedder for an OpenAI compatible endpoint.
+// With 0 dimensions the size is taken from the first response.
+func NewOpenAI(endpoint string, apiKey string, model string, dimensions int) *OpenAI {
+	if endpoint == "" {
+		endpoint = DefaultOpenAIEndpoint
+	}
+	if model == "" {
+		model = DefaultOpenAIModel
+	}
+	return &OpenAI{
+		provider:          ProviderOpenAI,
+		endpoint:          strings.TrimSuffix(endpoint, "/"),
+		apiKey:            apiKey,
+		model:             model,
+		HTTPClient:        http.DefaultClient,
+		dimensions:        dimensions,
+		requestDimensions: dimensions,
+	}
+}
+
+// NewOllama returns an embedder for the OpenAI compatible API of Ollama,
+// by default nomic-embed-text of a local server
+func NewOllama(endpoint string, apiKey string, model string, dimensions int) *OpenAI {
+	if endpoint == "" {
+		endpoint = DefaultOllamaEndpoint
+	}
+	if model == "" {
+		model = DefaultOllamaModel
+	}
+	ollama := NewOpenAI(endpoint, apiKey, model, dimensions)
+	ollama.provider = ProviderOllama
+	return ollama
+}
+
+// Name includes the provider and the requested dimensions, they change
+// the vectors. The endpoint is not included, the lambda function may reach
+// the same server with another URL.
+func (o *OpenAI) Name() string {
+	if o.requestDimensions > 0 {
+		return fmt.Sprintf("%v/%v?dimensions=%d", o.provider, o.model, o.requestDimensions)
+	}
+	return o.provider + "/" + o.model
+}
+
+func (o *OpenAI) Dimensions() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.dimensions
+}
+
+func (o *OpenAI) MaxInput() int {
+	// 8k tokens of text-embedding-3, most local models accept less
+	return 8000
+}
+
+func (o *OpenAI) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += openAIBatchSize {
+		end := min(start+openAIBatchSize, len(texts))
+		request := openAIRequest{Model: o.model, Dimensions: o.requestDimensions}
+		for _, text := range texts[start:end] {
+			request.Input = append(request.Input, truncate(text, o.MaxInput()))
+		}
+		batch, err := o.post(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		embeddings = append(embeddings, batch...)
+	}
+	return embeddings, nil
+}
+
+func (o *OpenAI) post(ctx context.Context, request openAIRequest) ([][]float32, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, o.endpoint+"/embeddings", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		httpRequest.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+	httpResponse, err := o.HTTPClient.Do(httpRequest)
+	if err != nil {
+		return nil, fmt.Errorf("calling %v: %w", o.Name(), err)
+	}
+	defer httpResponse.Body.Close()
+	body, err := io.ReadAll(httpResponse.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading %v response: %w", o.Name(), err)
+	}
+
+	var response openAIResponse
+	decodeErr := json.Unmarshal(body, &response)
+	if httpResponse.StatusCode != http.StatusOK {
+		message := strings.TrimSpace(string(body))
+		if decodeErr == nil && response.Error != nil {
+			message = response.Error.Message
+		}
+		return nil, &HTTPError{StatusCode: httpResponse.StatusCode, Message: message}
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("decoding %v response: %w", o.Name(), decodeErr)
+	}
+	if len(response.Data) != len(request.Input) {
+		return nil, fmt.Errorf("%v returned %d embeddings for %d texts", o.Name(), len(response.Data), len(request.Input))
+	}
+
+	// the data is ordered by index, which is not guaranteed by every server
+	embeddings := make([][]float32, len(response.Data))
+	for _, data := range response.Data {
+		if data.Index < 0 || data.Index >= len(embeddings) {
+			return nil, fmt.Errorf("%v returned index %d for %d texts", o.Name(), data.Index, len(embeddings))
+		}
+		embeddings[data.Index] = toFloat32(data.Embedding)
+	}
+	o.mu.Lock()
+	if o.dimensions == 0 && len(embeddings) > 0 {
+		o.dimensions = len(embeddings[0])
+	}
+	o.mu.Unlock()
+	return embeddings, nil
+}
+
+// HTTPError is a response of the embedding API which is not OK
+type HTTPError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("embedding request failed with status %d: %v", e.StatusCode, e.Message)
+}
@@ -0,0 +1,256 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aws/smithy-go"
+)
+
+// SchedulerOptions configures the Scheduler
+type SchedulerOptions struct {
+	// Workers is the number of concurrent requests
+	Workers int
+	// RequestsPerSecond limits the requests, 0 is unlimited
+	RequestsPerSecond float64
+	// BatchSize is the number of texts per request, 0 uses the
+	// limit of the provider
+	BatchSize int
+	// MaxRetries of a throttled or failed request
+	MaxRetries int
+	// InitialBackoff is doubled with each retry up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultSchedulerOptions stay below the default Bedrock quotas
+var DefaultSchedulerOptions = SchedulerOptions{
+	Workers:           4,
+	RequestsPerSecond: 10,
+	MaxRetries:        6,
+	InitialBackoff:    500 * time.Millisecond,
+	MaxBackoff:        30 * time.Second,
+}
+
+// Failure records a text which could not be embedded
+type Failure struct {
+	// Index of the text in the batch
+	Index int
+	Text  string
+	Err   error
+}
+
+// BatchError is returned by Scheduler.EmbedBatch if some texts could not
+// be embedded. The embeddings of the other texts are returned.
+type BatchError struct {
+	Failures []Failure
+}
+
+func (e *BatchError) Error() string {
+	if len(e.Failures) == 1 {
+		return fmt.Sprintf("embedding 1 text failed: %v", e.Failures[0].Err)
+	}
+	return fmt.Sprintf("embedding %d texts failed, first: %v", len(e.Failures), e.Failures[0].Err)
+}
+
+// Scheduler embeds batches concurrently with a bounded number of
+// workers and a request rate limit. Throttled and transient errors are
+// retried with exponential backoff and jitter.
+type Scheduler struct {
+	embedder Embedder
+	options  SchedulerOptions
+	workers  chan struct{}
+
+	mu       sync.Mutex
+	next     time.Time
+	failures []Failure
+}
+
+// NewScheduler wraps the embedder with the scheduler
+func NewScheduler(embedder Embedder, options SchedulerOptions) *Scheduler {
+	if options.Workers <= 0 {
+		options.Workers = 1
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = RequestBatchSize(embedder)
+	}
+	return &Scheduler{
+		embedder: embedder,
+		options:  options,
+		workers:  make(chan struct{}, options.Workers),
+	}
+}
+
+// RequestBatchSize is the number of texts the embedder sends in one request
+func RequestBatchSize(embedder Embedder) int {
+	switch embedder.(type) {
+	case *Titan:
+		return 1
+	case *Cohere:
+		return cohereBatchSize
+	case *OpenAI:
+		return openAIBatchSize
+	}
+	return 16
+}
+
+func (s *Scheduler) Name() string {
+	return s.embedder.Name()
+}
+
+func (s *Scheduler) Dimensions() int {
+	return s.embedder.Dimensions()
+}
+
+func (s *Scheduler) MaxInput() int {
+	return s.embedder.MaxInput()
+}
+
+// EmbedBatch splits the texts into requests and runs them on the workers.
+// If requests fail after all retries, the error is a *BatchError and the
+// embeddings of the failed texts are nil.
+func (s *Scheduler) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	var failures []Failure
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for start := 0; start < len(texts); start += s.options.BatchSize {
+		end := min(start+s.options.BatchSize, len(texts))
+		select {
+		case s.workers <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return nil, ctx.Err()
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			defer func() { <-s.workers }()
+			batch, err := s.embedWithRetry(ctx, texts[start:end])
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				for i := start; i < end; i++ {
+					failures = append(failures, Failure{Index: i, Text: texts[i], Err: err})
+				}
+				return
+			}
+			copy(embeddings[start:end], batch)
+		}(start, end)
+	}
+	wg.Wait()
+
+	if len(failures) == 0 {
+		return embeddings, nil
+	}
+	s.mu.Lock()
+	s.failures = append(s.failures, failures...)
+	s.mu.Unlock()
+	return embeddings, &BatchError{Failures: failures}
+}
+
+// Failures returns all failures since the scheduler was created
+func (s *Scheduler) Failures() []Failure {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Failure(nil), s.failures...)
+}
+
+func (s *Scheduler) embedWithRetry(ctx context.Context, texts []string) ([][]float32, error) {
+	for attempt := 0; ; attempt++ {
+		if err := s.wait(ctx); err != nil {
+			return nil, err
+		}
+		embeddings, err := s.embedder.EmbedBatch(ctx, texts)
+		if err == nil {
+			if len(embeddings) != len(texts) {
+				return nil, fmt.Errorf("%v returned %d embeddings for %d texts", s.Name(), len(embeddings), len(texts))
+			}
+			return embeddings, nil
+		}
+		if attempt >= s.options.MaxRetries || !Retryable(err) {
+			return nil, err
+		}
+		select {
+		case <-time.After(s.backoff(attempt)):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// wait blocks until the rate limit allows the next request
+func (s *Scheduler) wait(ctx context.Context) error {
+	if s.options.RequestsPerSecond <= 0 {
+		return ctx.Err()
+	}
+	interval := time.Duration(float64(time.Second) / s.options.RequestsPerSecond)
+	s.mu.Lock()
+	now := time.Now()
+	if s.next.Before(now) {
+		s.next = now
+	}
+	delay := s.next.Sub(now)
+	s.next = s.next.Add(interval)
+	s.mu.Unlock()
+
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	select {
+	case <-time.After(delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// backoff doubles the delay per attempt, the jitter spreads the retries
+// of the workers
+func (s *Scheduler) backoff(attempt int) time.Duration {
+	delay := s.options.InitialBackoff << attempt
+	if delay <= 0 || delay > s.options.MaxBackoff {
+		delay = s.options.MaxBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// retryableCodes are the Bedrock errors which go away by waiting
+var retryableCodes = map[string]bool{
+	"ThrottlingException":         true,
+	"TooManyRequestsException":    true,
+	"ServiceUnavailableException": true,
+	"InternalServerException":     true,
+	"ModelNotReadyException":      true,
+	"ModelTimeoutException":       true,
+}
+
+// Retryable reports whether the request may succeed when it is repeated
+func Retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiError smithy.APIError
+	if errors.As(err, &apiError) {
+		return retryableCodes[apiError.ErrorCode()]
+	}
+	var httpError *HTTPError
+	if errors.As(err, &httpError) {
+		return httpError.StatusCode == http.StatusTooManyRequests || httpError.StatusCode >= 500
+	}
+	var netError net.Error
+	if errors.As(err, &netError) && netError.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED)
+}
@@ -0,0 +1,144 @@
+package embedding_test
+
+import (
+	"context"
+	"errors"
+	"hugoembedding/embedding"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go"
+	"gotest.tools/v3/assert"
+)
+
+// flakyEmbedder throttles the first calls and fails texts containing "bad"
+type flakyEmbedder struct {
+	embedding.Embedder
+	mu         sync.Mutex
+	throttle   int
+	calls      int
+	active     int
+	maxActive  int
+	batchSizes []int
+}
+
+func (f *flakyEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	f.mu.Lock()
+	f.calls++
+	f.active++
+	f.maxActive = max(f.maxActive, f.active)
+	f.batchSizes = append(f.batchSizes, len(texts))
+	throttled := f.throttle > 0
+	if throttled {
+		f.throttle--
+	}
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		f.active--
+		f.mu.Unlock()
+	}()
+
+	time.Sleep(time.Millisecond)
+	if throttled {
+		return nil, &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Too many requests"}
+	}
+	for _, text := range texts {
+		if strings.Contains(text, "bad") {
+			return nil, &smithy.GenericAPIError{Code: "ValidationException", Message: "bad input"}
+		}
+	}
+	return f.Embedder.EmbedBatch(ctx, texts)
+}
+
+func testOptions() embedding.SchedulerOptions {
+	return embedding.SchedulerOptions{
+		Workers:        2,
+		BatchSize:      2,
+		MaxRetries:     3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+	}
+}
+
+func TestSchedulerRetriesThrottling(t *testing.T) {
+	hashing, err := embedding.NewHashing(8)
+	assert.NilError(t, err)
+	flaky := &flakyEmbedder{Embedder: hashing, throttle: 2}
+	scheduler := embedding.NewScheduler(flaky, testOptions())
+
+	texts := []string{"a1", "b2", "c3", "d4", "e5", "f6", "g7"}
+	embeddings, err := scheduler.EmbedBatch(context.TODO(), texts)
+	assert.NilError(t, err)
+	assert.Equal(t, len(embeddings), len(texts))
+	for i, text := range texts {
+		expected, err := embedding.Embed(context.TODO(), hashing, text)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, embeddings[i], expected)
+	}
+	// 4 batches and 2 throttled calls
+	assert.Equal(t, flaky.calls, 6)
+	assert.Assert(t, flaky.maxActive <= 2)
+	for _, n := range flaky.batchSizes {
+		assert.Assert(t, n <= 2)
+	}
+	assert.Equal(t, len(scheduler.Failures()), 0)
+}
+
+func TestSchedulerRecordsFailures(t *testing.T) {
+	hashing, err := embedding.NewHashing(8)
+	assert.NilError(t, err)
+	flaky := &flakyEmbedder{Embedder: hashing}
+	scheduler := embedding.NewScheduler(flaky, testOptions())
+
+	embeddings, err := scheduler.EmbedBatch(context.TODO(), []string{"good", "fine", "bad", "okay"})
+	var batchError *embedding.BatchError
+	assert.Assert(t, errors.As(err, &batchError))
+	assert.Equal(t, len(batchError.Failures), 2)
+	// the batch of the bad text fails without retry
+	assert.Equal(t, flaky.calls, 2)
+	assert.Assert(t, embeddings[0] != nil)
+	assert.Assert(t, embeddings[1] != nil)
+	assert.Assert(t, embeddings[2] == nil)
+	assert.Assert(t, embeddings[3] == nil)
+	assert.Equal(t, len(scheduler.Failures()), 2)
+	assert.Equal(t, scheduler.Failures()[0].Index+scheduler.Failures()[1].Index, 5)
+}
+
+func TestSchedulerGivesUp(t *testing.T) {
+	hashing, err := embedding.NewHashing(8)
+	assert.NilError(t, err)
+	flaky := &flakyEmbedder{Embedder: hashing, throttle: 100}
+	scheduler := embedding.NewScheduler(flaky, testOptions())
+
+	_, err = embedding.Embed(context.TODO(), scheduler, "text")
+	assert.ErrorContains(t, err, "ThrottlingException")
+	// the first call and the retries
+	assert.Equal(t, flaky.calls, 4)
+}
+
+func TestSchedulerRateLimit(t *testing.T) {
+	hashing, err := embedding.NewHashing(8)
+	assert.NilError(t, err)
+	options := testOptions()
+	options.RequestsPerSecond = 100
+	options.BatchSize = 1
+	scheduler := embedding.NewScheduler(hashing, options)
+
+	start := time.Now()
+	_, err = scheduler.EmbedBatch(context.TODO(), []string{"a", "b", "c", "d", "e", "f"})
+	assert.NilError(t, err)
+	// six requests at 10ms intervals
+	assert.Assert(t, time.Since(start) >= 50*time.Millisecond)
+}
+
+func TestRetryable(t *testing.T) {
+	assert.Assert(t, embedding.Retryable(&smithy.GenericAPIError{Code: "ThrottlingException"}))
+	assert.Assert(t, !embedding.Retryable(&smithy.GenericAPIError{Code: "AccessDeniedException"}))
+	assert.Assert(t, embedding.Retryable(&embedding.HTTPError{StatusCode: 429}))
+	assert.Assert(t, embedding.Retryable(&embedding.HTTPError{StatusCode: 503}))
+	assert.Assert(t, !embedding.Retryable(&embedding.HTTPError{StatusCode: 400}))
+	assert.Assert(t, !embedding.Retryable(context.Canceled))
+}
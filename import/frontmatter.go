@@ -0,0 +1,280 @@
+package hugoembedding
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// Metadata is the front matter of a post, see
+// https://gohugo.io/content-management/front-matter/
+type Metadata struct {
+	Title       string
+	Author      string
+	Tags        []string
+	Categories  []string
+	Keywords    []string
+	Date        string
+	Draft       bool
+	Aliases     []string
+	Slug        string
+	URL         string
+	Description string
+	Language    string
+}
+
+// ListSeparator separates the tags and categories in the metadata
+// of a document
+const ListSeparator = ","
+
+// FrontMatterFormat is the format of a Hugo front matter block
+type FrontMatterFormat int
+
+const (
+	FrontMatterNone FrontMatterFormat = iota
+	FrontMatterYAML
+	FrontMatterTOML
+	FrontMatterJSON
+)
+
+func (f FrontMatterFormat) String() string {
+	switch f {
+	case FrontMatterYAML:
+		return "yaml"
+	case FrontMatterTOML:
+		return "toml"
+	case FrontMatterJSON:
+		return "json"
+	}
+	return "none"
+}
+
+// SplitFrontMatter separates the front matter from the markdown body.
+// Hugo knows three formats:
+//
+//	--- YAML ---
+//	+++ TOML +++
+//	{ JSON }
+//
+// The returned front matter is without the delimiters (JSON keeps its braces).
+// If there is no front matter, the whole source is returned as body.
+func SplitFrontMatter(source []byte) (FrontMatterFormat, []byte, []byte) {
+	// a leading byte order mark or stray blanks would hide the delimiter
+	src := bytes.TrimPrefix(source, []byte("\xef\xbb\xbf"))
+	src = bytes.TrimLeft(src, " \t\r\n")
+
+	first, _, _ := bytes.Cut(src, []byte("\n"))
+	delimiter := string(bytes.TrimRight(first, " \t\r"))
+
+	switch {
+	case isDelimiter(delimiter, '-'):
+		if front, body, ok := splitDelimited(src, '-'); ok {
+			return FrontMatterYAML, front, body
+		}
+	case isDelimiter(delimiter, '+'):
+		if front, body, ok := splitDelimited(src, '+'); ok {
+			return FrontMatterTOML, front, body
+		}
+	case isJSONStart(src):
+		if end := jsonObjectEnd(src); end > 0 && json.Valid(src[:end]) {
+			return FrontMatterJSON, src[:end], src[end:]
+		}
+	}
+	return FrontMatterNone, nil, source
+}
+
+// isJSONStart checks whether the source opens a JSON object, a body which
+// starts with a shortcode like {{< toc >}} is no front matter
+func isJSONStart(src []byte) bool {
+	if len(src) < 2 || src[0] != '{' {
+		return false
+	}
+	switch src[1] {
+	case '\n', '\r', ' ', '\t', '"':
+		return true
+	}
+	return false
+}
+
+// isDelimiter checks whether the line consists of at least three
+// delimiter characters only, some older posts use more than three dashes
+func isDelimiter(line string, c byte) bool {
+	if len(line) < 3 {
+		return false
+	}
+	for i := 0; i < len(line); i++ {
+		if line[i] != c {
+			return false
+		}
+	}
+	return true
+}
+
+// splitDelimited returns the block between the opening and the closing
+// delimiter line and the rest of the source after the closing line
+func splitDelimited(src []byte, delimiter byte) ([]byte, []byte, bool) {
+	_, rest, found := bytes.Cut(src, []byte("\n"))
+	if !found {
+		return nil, nil, false
+	}
+	offset := 0
+	for offset <= len(rest) {
+		line, _, _ := bytes.Cut(rest[offset:], []byte("\n"))
+		if isDelimiter(string(bytes.TrimRight(line, " \t\r")), delimiter) {
+			front := rest[:offset]
+			end := offset + len(line)
+			if end < len(rest) {
+				end++ // skip newline
+			}
+			return front, rest[end:], true
+		}
+		if offset+len(line) >= len(rest) {
+			break
+		}
+		offset += len(line) + 1
+	}
+	return nil, nil, false
+}
+
+// jsonObjectEnd returns the index after the closing brace of the leading
+// JSON object or -1 if the object is not closed
+func jsonObjectEnd(src []byte) int {
+	depth := 0
+	inString := false
+	escaped := false
+	for i, b := range src {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case b == '\\':
+				escaped = true
+			case b == '"':
+				inString = false
+			}
+			continue
+		}
+		switch b {
+		case '"':
+			inString = true
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+// ParseMetadata reads the front matter of a markdown file.
+// YAML, TOML and JSON front matter is decoded into the same Metadata.
+func ParseMetadata(source []byte) (*Metadata, error) {
+	format, front, _ := SplitFrontMatter(source)
+
+	values := map[string]interface{}{}
+	var err error
+	switch format {
+	case FrontMatterNone:
+		return &Metadata{}, nil
+	case FrontMatterYAML:
+		err = yaml.Unmarshal(front, &values)
+	case FrontMatterTOML:
+		err = toml.Unmarshal(front, &values)
+	case FrontMatterJSON:
+		err = json.Unmarshal(front, &values)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decoding %v front matter: %w", format, err)
+	}
+
+	// Hugo front matter keys are case insensitive
+	fields := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		fields[strings.ToLower(key)] = value
+	}
+
+	meta := &Metadata{
+		Title:       stringValue(fields["title"]),
+		Author:      stringValue(fields["author"]),
+		Tags:        stringList(fields["tags"]),
+		Categories:  stringList(fields["categories"]),
+		Keywords:    stringList(fields["keywords"]),
+		Date:        stringValue(fields["date"]),
+		Draft:       boolValue(fields["draft"]),
+		Aliases:     stringList(fields["aliases"]),
+		Slug:        stringValue(fields["slug"]),
+		URL:         stringValue(fields["url"]),
+		Description: stringValue(fields["description"]),
+		Language:    stringValue(fields["language"]),
+	}
+	if meta.Language == "" {
+		meta.Language = stringValue(fields["lang"])
+	}
+	if meta.Author == "" {
+		if authors := stringList(fields["authors"]); len(authors) > 0 {
+			meta.Author = authors[0]
+		}
+	}
+	return meta, nil
+}
+
+func stringValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		// TOML dates are typed, keep the formats TryParseDateMonth knows
+		if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 {
+			return v.Format("2006-01-02")
+		}
+		return v.Format(time.RFC3339)
+	case []interface{}:
+		if len(v) > 0 {
+			return stringValue(v[0])
+		}
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// stringList accepts a list or a single value, "tags: aws" is valid in Hugo
+func stringList(value interface{}) []string {
+	var list []string
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		for _, item := range v {
+			if s := stringValue(item); s != "" {
+				list = append(list, s)
+			}
+		}
+	case []string:
+		list = v
+	default:
+		if s := stringValue(v); s != "" {
+			list = []string{s}
+		}
+	}
+	return list
+}
+
+func boolValue(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return strings.EqualFold(v, "true")
+	}
+	return false
+}
@@ -0,0 +1,203 @@
+package hugoembedding_test
+
+import (
+	"bytes"
+	"hugoembedding"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestSplitFrontMatter(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		format hugoembedding.FrontMatterFormat
+		front  string
+		body   string
+	}{
+		{
+			name:   "yaml",
+			source: "---\ntitle: \"Hello\"\n---\n# Body\n",
+			format: hugoembedding.FrontMatterYAML,
+			front:  "title: \"Hello\"\n",
+			body:   "# Body\n",
+		},
+		{
+			name:   "yaml with long delimiters",
+			source: "------\ntitle: \"Hello\"\n---\nBody",
+			format: hugoembedding.FrontMatterYAML,
+			front:  "title: \"Hello\"\n",
+			body:   "Body",
+		},
+		{
+			name:   "toml",
+			source: "+++\ntitle = \"Hello\"\n+++\nBody",
+			format: hugoembedding.FrontMatterTOML,
+			front:  "title = \"Hello\"\n",
+			body:   "Body",
+		},
+		{
+			name:   "json",
+			source: "{\n  \"title\": \"Hello {world}\"\n}\nBody",
+			format: hugoembedding.FrontMatterJSON,
+			front:  "{\n  \"title\": \"Hello {world}\"\n}",
+			body:   "\nBody",
+		},
+		{
+			name:   "shortcode",
+			source: "{{< toc >}}\n\n# Body\n",
+			format: hugoembedding.FrontMatterNone,
+			front:  "",
+			body:   "{{< toc >}}\n\n# Body\n",
+		},
+		{
+			name:   "invalid json",
+			source: "{ see below }\nBody",
+			format: hugoembedding.FrontMatterNone,
+			front:  "",
+			body:   "{ see below }\nBody",
+		},
+		{
+			name:   "none",
+			source: "# Body\n\n---\n",
+			format: hugoembedding.FrontMatterNone,
+			front:  "",
+			body:   "# Body\n\n---\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, front, body := hugoembedding.SplitFrontMatter([]byte(tt.source))
+			assert.Equal(t, format, tt.format)
+			assert.Equal(t, string(front), tt.front)
+			assert.Equal(t, string(body), tt.body)
+		})
+	}
+}
+
+// No chunk of the test corpus may contain lines of the front matter
+func TestParseWithoutFrontMatter(t *testing.T) {
+	err := filepath.Walk("testdata", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".md") {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		assert.NilError(t, err)
+
+		_, front, _ := hugoembedding.SplitFrontMatter(content)
+		var keyLines []string
+		for _, line := range bytes.Split(front, []byte("\n")) {
+			if bytes.HasPrefix(line, []byte("title:")) || bytes.HasPrefix(line, []byte("categories:")) {
+				keyLines = append(keyLines, string(bytes.TrimSpace(line)))
+			}
+		}
+
+		chunks, err := hugoembedding.Parse(content, hugoembedding.DefaultConfig())
+		assert.NilError(t, err)
+		for _, chunk := range *chunks {
+			for _, line := range keyLines {
+				assert.Assert(t, !strings.Contains(*chunk.Chunk, line), "%s: front matter %q in chunk", path, line)
+			}
+		}
+		return nil
+	})
+	assert.NilError(t, err)
+}
+
+func TestParseMetadata(t *testing.T) {
+	expected := hugoembedding.Metadata{
+		Title:       "Hello",
+		Author:      "Gernot Glawe",
+		Tags:        []string{"go", "aws"},
+		Categories:  []string{"development"},
+		Keywords:    []string{"bedrock"},
+		Date:        "2024-02-11",
+		Draft:       true,
+		Aliases:     []string{"/old/hello"},
+		Slug:        "hello",
+		URL:         "/2024/02/hello.html",
+		Description: "Say hello",
+		Language:    "en",
+	}
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{
+			name: "yaml",
+			source: `---
+title: "Hello"
+author: "Gernot Glawe"
+date: 2024-02-11
+draft: true
+tags:
+    - go
+    - aws
+categories: [development]
+keywords: bedrock
+aliases: ["/old/hello"]
+slug: hello
+url: /2024/02/hello.html
+description: Say hello
+language: en
+---
+Body`,
+		},
+		{
+			name: "toml",
+			source: `+++
+title = "Hello"
+authors = ["Gernot Glawe"]
+date = 2024-02-11
+draft = true
+tags = ["go", "aws"]
+categories = ["development"]
+keywords = ["bedrock"]
+aliases = ["/old/hello"]
+slug = "hello"
+url = "/2024/02/hello.html"
+description = "Say hello"
+lang = "en"
++++
+Body`,
+		},
+		{
+			name: "json",
+			source: `{
+  "Title": "Hello",
+  "author": "Gernot Glawe",
+  "date": "2024-02-11",
+  "draft": true,
+  "tags": ["go", "aws"],
+  "categories": ["development"],
+  "keywords": ["bedrock"],
+  "aliases": ["/old/hello"],
+  "slug": "hello",
+  "url": "/2024/02/hello.html",
+  "description": "Say hello",
+  "language": "en"
+}
+Body`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, err := hugoembedding.ParseMetadata([]byte(tt.source))
+			assert.NilError(t, err)
+			assert.DeepEqual(t, *meta, expected)
+		})
+	}
+}
+
+func TestParseMetadataShortcodeBody(t *testing.T) {
+	meta, err := hugoembedding.ParseMetadata([]byte("{{< toc >}}\n\n# Body\n"))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, *meta, hugoembedding.Metadata{})
+}
@@ -0,0 +1,20 @@
+package hugoembedding
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+)
+
+// IDScheme names the way DocumentID builds the IDs, a database with
+// another scheme is imported again
+const IDScheme = "source-sha256-ordinal"
+
+// DocumentID is the stable ID of the chunk with the ordinal in the markdown
+// file, e.g. "3f2a9c1b7d4e-0007". The same chunk of the same file gets the
+// same ID in each import.
+func DocumentID(source string, ordinal int) string {
+	hash := sha256.Sum256([]byte(filepath.ToSlash(source)))
+	return fmt.Sprintf("%v-%04d", hex.EncodeToString(hash[:6]), ordinal)
+}
@@ -0,0 +1,17 @@
+package hugoembedding_test
+
+import (
+	"hugoembedding"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestDocumentID(t *testing.T) {
+	id := hugoembedding.DocumentID("content/post/2024/zed/index.md", 7)
+	assert.Equal(t, id, hugoembedding.DocumentID("content/post/2024/zed/index.md", 7))
+	assert.Equal(t, len(id), 17)
+	assert.Equal(t, id[12:], "-0007")
+	assert.Assert(t, id != hugoembedding.DocumentID("content/post/2024/zed/index.md", 8))
+	assert.Assert(t, id != hugoembedding.DocumentID("content/post/2024/vim/index.md", 7))
+}
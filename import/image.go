@@ -0,0 +1,68 @@
+package hugoembedding
+
+import (
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// imageRef is an image with its describing texts
+type imageRef struct {
+	Destination string
+	Alt         string
+	Title       string
+}
+
+// Text is the searchable description of the image
+func (i imageRef) Text() string {
+	parts := make([]string, 0, 2)
+	if i.Alt != "" {
+		parts = append(parts, i.Alt)
+	}
+	if i.Title != "" && i.Title != i.Alt {
+		parts = append(parts, i.Title)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// extractImages returns all images below the node
+func extractImages(node ast.Node, source []byte) []imageRef {
+	var images []imageRef
+	_ = ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering {
+			if img, ok := n.(*ast.Image); ok {
+				images = append(images, imageRef{
+					Destination: string(img.Destination),
+					Alt:         strings.TrimSpace(string(img.Text(source))),
+					Title:       strings.TrimSpace(string(img.Title)),
+				})
+				return ast.WalkSkipChildren, nil
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return images
+}
+
+// figureImage turns a Hugo figure shortcode into a markdown image,
+// so that the caption becomes an image chunk
+func figureImage(named map[string]string) []byte {
+	src := named["src"]
+	if src == "" {
+		return nil
+	}
+	alt := named["alt"]
+	caption := named["caption"]
+	if caption == "" {
+		caption = named["title"]
+	}
+	alt = strings.NewReplacer("[", "", "]", "").Replace(alt)
+	caption = strings.ReplaceAll(caption, `"`, `\"`)
+
+	image := "![" + alt + "](<" + src + ">"
+	if caption != "" {
+		image += ` "` + caption + `"`
+	}
+	// an own paragraph for the image
+	return []byte("\n\n" + image + ")\n\n")
+}
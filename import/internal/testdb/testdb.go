@@ -0,0 +1,78 @@
+// Package testdb creates the chromem databases of the tests with the
+// offline hashing embedder
+package testdb
+
+import (
+	"context"
+	he "hugoembedding"
+	"hugoembedding/embedding"
+	"hugoembedding/localstore"
+	"hugoembedding/vectorstore"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+// DB is a chromem database which is written to Path on Close
+type DB struct {
+	Embedder embedding.Embedder
+	Path     string
+	Store    *vectorstore.Chromem
+}
+
+// New creates an empty database in a temporary directory, 0 dimensions
+// are the default of the hashing embedder
+func New(t *testing.T, dimensions int) *DB {
+	embedder, err := embedding.NewHashing(dimensions)
+	assert.NilError(t, err)
+	db, err := localstore.Init(embedder)
+	assert.NilError(t, err)
+	path := filepath.Join(t.TempDir(), "db.gob")
+	store, err := vectorstore.NewChromem(db, localstore.StoreFunc(localstore.FileOptions{Path: path}, context.Background()))
+	assert.NilError(t, err)
+	return &DB{Embedder: embedder, Path: path, Store: store}
+}
+
+// Document embeds the content of a new document of the source
+func (d *DB) Document(t *testing.T, id, source, content string) vectorstore.Document {
+	vector, err := embedding.Embed(context.Background(), d.Embedder, content)
+	assert.NilError(t, err)
+	return vectorstore.Document{
+		ID:        id,
+		Content:   content,
+		Embedding: vector,
+		Metadata:  map[string]string{"source": source},
+	}
+}
+
+// Import processes the posts like the importer
+func (d *DB) Import(t *testing.T, cfg *he.Config, posts ...string) {
+	for _, post := range posts {
+		assert.NilError(t, localstore.ProcessIndex(post, 1, cfg, d.Embedder, d.Store, context.Background()))
+	}
+}
+
+// Close writes the database and the manifest of the posts and returns
+// the number of documents
+func (d *DB) Close(t *testing.T, cfg *he.Config, posts ...string) int {
+	ctx := context.Background()
+	documents, err := d.Store.Count(ctx)
+	assert.NilError(t, err)
+	assert.NilError(t, d.Store.Close())
+	files, err := localstore.HashFiles(posts)
+	assert.NilError(t, err)
+	assert.NilError(t, localstore.WriteManifest(d.Path, localstore.NewManifest(cfg, vectorstore.KindChromem, d.Embedder, files, documents)))
+	return documents
+}
+
+// Load reads the written database read only, it is iterated with the
+// dimensions of the embedder
+func (d *DB) Load(t *testing.T) *vectorstore.Chromem {
+	db, err := localstore.Load(localstore.FileOptions{Path: d.Path}, d.Embedder, context.Background())
+	assert.NilError(t, err)
+	store, err := vectorstore.NewChromem(db, nil)
+	assert.NilError(t, err)
+	store.SetDimensions(d.Embedder.Dimensions())
+	return store
+}
@@ -0,0 +1,92 @@
+package hugoembedding
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// LinkSeparator separates the link targets of a chunk in the metadata
+const LinkSeparator = " "
+
+// refShortcode matches {{< ref "post/2023/x/index.md" >}} and
+// {{% relref "x.md#anchor" %}}
+var refShortcode = regexp.MustCompile(`\{\{[<%]\s*(?:rel)?ref\s+"?([^"\s>%]+)"?\s*[>%]\}\}`)
+
+// resolveRefShortcodes replaces Hugo ref and relref shortcodes with the
+// site path of the referenced page. Otherwise goldmark does not see a link
+// in [text]({{< ref "post.md" >}}). Shortcodes in code are kept.
+func resolveRefShortcodes(source []byte) []byte {
+	code := codeRanges(source)
+	var out []byte
+	pos := 0
+	for _, match := range refShortcode.FindAllSubmatchIndex(source, -1) {
+		if _, ok := inCode(code, match[0]); ok {
+			continue
+		}
+		out = append(out, source[pos:match[0]]...)
+		out = append(out, RefPath(string(source[match[2]:match[3]]))...)
+		pos = match[1]
+	}
+	return append(out, source[pos:]...)
+}
+
+// RefPath converts the content path of a ref shortcode to the site path
+// post/2023/my-post/index.md#setup => /post/2023/my-post/#setup
+func RefPath(ref string) string {
+	ref, anchor, _ := strings.Cut(ref, "#")
+	if ref == "" {
+		return "#" + anchor
+	}
+	ref = strings.TrimPrefix(ref, "/")
+	ref = strings.TrimPrefix(ref, "content/")
+	switch {
+	case path.Base(ref) == "index.md" || path.Base(ref) == "_index.md":
+		ref = path.Dir(ref) + "/"
+	case strings.HasSuffix(ref, ".md"):
+		ref = strings.TrimSuffix(ref, ".md") + "/"
+	}
+	link := "/" + ref
+	if anchor != "" {
+		link += "#" + anchor
+	}
+	return link
+}
+
+// ResolveLink returns the final URL of a link target.
+// Absolute URLs are kept, site paths like /post/... are resolved against
+// the base URL and relative targets against the page link.
+// With an empty base URL, internal links stay site paths.
+func ResolveLink(baseURL string, pageLink string, destination string) string {
+	target, err := url.Parse(strings.TrimSpace(destination))
+	if err != nil {
+		Logger.Debug("Unresolvable link", "destination", destination, "error", err)
+		return destination
+	}
+	if target.IsAbs() {
+		return target.String()
+	}
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		Logger.Debug("Invalid base url", "base", baseURL, "error", err)
+		return destination
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	page, err := url.Parse(strings.TrimPrefix(pageLink, "/"))
+	if err != nil {
+		return destination
+	}
+	return base.ResolveReference(page).ResolveReference(target).String()
+}
+
+// ResolveLinks resolves all link targets of a chunk, see ResolveLink
+func ResolveLinks(baseURL string, pageLink string, destinations []string) []string {
+	links := make([]string, 0, len(destinations))
+	for _, destination := range destinations {
+		links = append(links, ResolveLink(baseURL, pageLink, destination))
+	}
+	return links
+}
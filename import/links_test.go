@@ -0,0 +1,37 @@
+package hugoembedding_test
+
+import (
+	"hugoembedding"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestResolveLink(t *testing.T) {
+	const base = "https://www.go-on-aws.com/"
+	const page = "post/2024/my-post/"
+	tests := []struct {
+		name        string
+		base        string
+		destination string
+		want        string
+	}{
+		{"absolute", base, "https://aws.amazon.com/lambda/", "https://aws.amazon.com/lambda/"},
+		{"site path", base, "/post/2023/other/", "https://www.go-on-aws.com/post/2023/other/"},
+		{"relative", base, "diagram.png", "https://www.go-on-aws.com/post/2024/my-post/diagram.png"},
+		{"anchor", base, "#setup", "https://www.go-on-aws.com/post/2024/my-post/#setup"},
+		{"base without slash", "https://www.go-on-aws.com", "/post/x/", "https://www.go-on-aws.com/post/x/"},
+		{"no base", "", "/post/2023/other/", "/post/2023/other/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, hugoembedding.ResolveLink(tt.base, page, tt.destination), tt.want)
+		})
+	}
+}
+
+func TestRefPath(t *testing.T) {
+	assert.Equal(t, hugoembedding.RefPath("post/2023/my-post/index.md"), "/post/2023/my-post/")
+	assert.Equal(t, hugoembedding.RefPath("/content/post/2023/my-post.md#setup"), "/post/2023/my-post/#setup")
+	assert.Equal(t, hugoembedding.RefPath("#setup"), "#setup")
+}
@@ -0,0 +1,308 @@
+package localstore
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"hugoembedding"
+	"hugoembedding/vectorstore"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/philippgille/chromem-go"
+)
+
+// FileOptions configure the chromem database file. A file is encrypted
+// with AES-GCM by a key of Key, KeyFile or a data key wrapped by KMSKeyID,
+// the first which is set.
+type FileOptions struct {
+	Path string
+	// Compress writes the file with gzip, compressed files are detected
+	// when reading
+	Compress bool
+	// Key is the base64 encoded 32 byte AES key
+	Key string
+	// KeyFile contains the base64 encoded key
+	KeyFile string
+	// KMSKeyID is the KMS key which wraps a data key per file, the
+	// wrapped key is stored beside the file, see KeyPath.
+	// "local:<master key file>" wraps the key with LocalKMS.
+	KMSKeyID string
+}
+
+// DefaultFileOptions write the uncompressed and unencrypted DBPath
+func DefaultFileOptions() FileOptions {
+	return FileOptions{Path: DBPath}
+}
+
+// FileOptionsFromEnv overrides the options with DB_PATH, DB_COMPRESS,
+// DB_ENCRYPTION_KEY, DB_ENCRYPTION_KEY_FILE and DB_KMS_KEY_ID
+func FileOptionsFromEnv(options FileOptions) FileOptions {
+	if path := os.Getenv("DB_PATH"); path != "" {
+		options.Path = path
+	}
+	if compress, err := strconv.ParseBool(os.Getenv("DB_COMPRESS")); err == nil {
+		options.Compress = compress
+	}
+	if key := os.Getenv("DB_ENCRYPTION_KEY"); key != "" {
+		options.Key = key
+	}
+	if keyFile := os.Getenv("DB_ENCRYPTION_KEY_FILE"); keyFile != "" {
+		options.KeyFile = keyFile
+	}
+	if keyID := os.Getenv("DB_KMS_KEY_ID"); keyID != "" {
+		options.KMSKeyID = keyID
+	}
+	return options
+}
+
+// CheckStore rejects compression and encryption for a SQLite database,
+// they only apply to the chromem file
+func (o FileOptions) CheckStore(store string) error {
+	if store != vectorstore.KindSQLite {
+		return nil
+	}
+	if o.Compress {
+		return errors.New("sqlite database cannot be compressed, only chromem")
+	}
+	if o.Key != "" || o.KeyFile != "" || o.KMSKeyID != "" {
+		return errors.New("sqlite database cannot be encrypted, only chromem")
+	}
+	return nil
+}
+
+// KeyPath is the wrapped data key of the database file,
+// e.g. db-data/db.gob => db-data/db.gob.key
+func KeyPath(dbPath string) string {
+	return dbPath + ".key"
+}
+
+// ReadKey returns the key of an existing file, empty if the file is not
+// encrypted. A KMS data key is unwrapped from the key file.
+func (o FileOptions) ReadKey(ctx context.Context) (string, error) {
+	if o.KMSKeyID == "" || o.Key != "" || o.KeyFile != "" {
+		return o.staticKey()
+	}
+	wrapped, err := readBase64(KeyPath(o.Path))
+	if err != nil {
+		return "", fmt.Errorf("reading data key: %w", err)
+	}
+	service, err := NewKeyService(o.KMSKeyID, ctx)
+	if err != nil {
+		return "", err
+	}
+	key, err := service.Decrypt(ctx, wrapped)
+	if err != nil {
+		return "", fmt.Errorf("decrypting data key: %w", err)
+	}
+	return checkKey(key)
+}
+
+// newKey returns the key of a new file and with KMS the wrapped data key
+func (o FileOptions) newKey(ctx context.Context) (string, []byte, error) {
+	if o.KMSKeyID == "" || o.Key != "" || o.KeyFile != "" {
+		key, err := o.staticKey()
+		return key, nil, err
+	}
+	service, err := NewKeyService(o.KMSKeyID, ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	key, wrapped, err := service.GenerateDataKey(ctx)
+	if err != nil {
+		return "", nil, fmt.Errorf("generating data key: %w", err)
+	}
+	checked, err := checkKey(key)
+	return checked, wrapped, err
+}
+
+func (o FileOptions) staticKey() (string, error) {
+	switch {
+	case o.Key != "":
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(o.Key))
+		if err != nil {
+			return "", fmt.Errorf("decoding encryption key: %w", err)
+		}
+		return checkKey(key)
+	case o.KeyFile != "":
+		key, err := readBase64(o.KeyFile)
+		if err != nil {
+			return "", fmt.Errorf("reading encryption key: %w", err)
+		}
+		return checkKey(key)
+	}
+	return "", nil
+}
+
+// checkKey returns the key as chromem expects it
+func checkKey(key []byte) (string, error) {
+	if len(key) != 32 {
+		return "", fmt.Errorf("encryption key has %d bytes, expected 32", len(key))
+	}
+	return string(key), nil
+}
+
+// GenerateKey returns a new base64 encoded key, e.g. for a master key
+// file or DB_ENCRYPTION_KEY
+func GenerateKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+func readBase64(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+}
+
+// Store writes the database file. A new KMS data key is generated for
+// each file. The file and its key are written to temporary files, then
+// the file is replaced and right after it the key.
+func Store(db *chromem.DB, options FileOptions, ctx context.Context) error {
+	log := hugoembedding.Logger
+	key, wrapped, err := options.newKey(ctx)
+	if err != nil {
+		return err
+	}
+	log.Info("Storing Database", "path", options.Path, "compress", options.Compress, "encrypted", key != "")
+	temporary := options.Path + ".tmp"
+	temporaryKey := KeyPath(temporary)
+	if wrapped != nil {
+		encoded := base64.StdEncoding.EncodeToString(wrapped) + "\n"
+		if err := os.WriteFile(temporaryKey, []byte(encoded), 0o644); err != nil {
+			os.Remove(temporaryKey)
+			return err
+		}
+	}
+	if err := db.Export(temporary, options.Compress, key); err != nil {
+		os.Remove(temporary)
+		os.Remove(temporaryKey)
+		return err
+	}
+	if err := os.Rename(temporary, options.Path); err != nil {
+		os.Remove(temporary)
+		os.Remove(temporaryKey)
+		return err
+	}
+	if wrapped != nil {
+		return os.Rename(temporaryKey, KeyPath(options.Path))
+	}
+	return nil
+}
+
+// StoreFunc writes the database with Store when a chromem store is closed
+func StoreFunc(options FileOptions, ctx context.Context) func(*chromem.DB) error {
+	return func(db *chromem.DB) error {
+		return Store(db, options, ctx)
+	}
+}
+
+// KeyService generates and unwraps the data keys of database files
+type KeyService interface {
+	// GenerateDataKey returns a new key and the key wrapped by the service
+	GenerateDataKey(ctx context.Context) ([]byte, []byte, error)
+	// Decrypt unwraps a key of GenerateDataKey
+	Decrypt(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// NewKeyService uses AWS KMS, "local:<master key file>" LocalKMS
+func NewKeyService(keyID string, ctx context.Context) (KeyService, error) {
+	if masterKeyFile, ok := strings.CutPrefix(keyID, "local:"); ok {
+		return NewLocalKMS(masterKeyFile)
+	}
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &KMS{client: kms.NewFromConfig(cfg), keyID: keyID}, nil
+}
+
+// KMS wraps the data keys with an AWS KMS key
+type KMS struct {
+	client *kms.Client
+	keyID  string
+}
+
+func (k *KMS) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	out, err := k.client.GenerateDataKey(ctx, &kms.GenerateDataKeyInput{
+		KeyId:   aws.String(k.keyID),
+		KeySpec: types.DataKeySpecAes256,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return out.Plaintext, out.CiphertextBlob, nil
+}
+
+func (k *KMS) Decrypt(ctx context.Context, wrapped []byte) ([]byte, error) {
+	out, err := k.client.Decrypt(ctx, &kms.DecryptInput{
+		CiphertextBlob: wrapped,
+		KeyId:          aws.String(k.keyID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Plaintext, nil
+}
+
+// LocalKMS stands in for KMS without an AWS account, the data keys are
+// wrapped with AES-GCM by a master key of a local file
+type LocalKMS struct {
+	gcm cipher.AEAD
+}
+
+// NewLocalKMS reads the base64 encoded 32 byte master key
+func NewLocalKMS(masterKeyFile string) (*LocalKMS, error) {
+	masterKey, err := readBase64(masterKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading master key: %w", err)
+	}
+	if _, err := checkKey(masterKey); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalKMS{gcm: gcm}, nil
+}
+
+func (l *LocalKMS) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	key := make([]byte, 32)
+	nonce := make([]byte, l.gcm.NonceSize())
+	for _, random := range [][]byte{key, nonce} {
+		if _, err := io.ReadFull(rand.Reader, random); err != nil {
+			return nil, nil, err
+		}
+	}
+	// the nonce is stored before the wrapped key
+	return key, l.gcm.Seal(nonce, nonce, key, nil), nil
+}
+
+func (l *LocalKMS) Decrypt(ctx context.Context, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < l.gcm.NonceSize() {
+		return nil, errors.New("wrapped key is too short")
+	}
+	nonce, ciphertext := wrapped[:l.gcm.NonceSize()], wrapped[l.gcm.NonceSize():]
+	return l.gcm.Open(nil, nonce, ciphertext, nil)
+}
@@ -0,0 +1,112 @@
+package localstore_test
+
+import (
+	"bytes"
+	"context"
+	"hugoembedding/internal/testdb"
+	"hugoembedding/localstore"
+	"hugoembedding/vectorstore"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestStoreFile(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	key, err := localstore.GenerateKey()
+	assert.NilError(t, err)
+	keyFile := filepath.Join(dir, "key")
+	assert.NilError(t, os.WriteFile(keyFile, []byte(key+"\n"), 0o600))
+	masterKey, err := localstore.GenerateKey()
+	assert.NilError(t, err)
+	masterKeyFile := filepath.Join(dir, "master.key")
+	assert.NilError(t, os.WriteFile(masterKeyFile, []byte(masterKey), 0o600))
+
+	tests := []struct {
+		name    string
+		options localstore.FileOptions
+		// the content is readable in the file
+		plaintext bool
+	}{
+		{"plain", localstore.FileOptions{}, true},
+		{"compressed", localstore.FileOptions{Compress: true}, false},
+		{"key", localstore.FileOptions{Key: key}, false},
+		{"key file compressed", localstore.FileOptions{KeyFile: keyFile, Compress: true}, false},
+		{"local kms", localstore.FileOptions{KMSKeyID: "local:" + masterKeyFile}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := test.options
+			options.Path = filepath.Join(t.TempDir(), "db.gob")
+			db := testdb.New(t, 64)
+			assert.NilError(t, db.Store.Upsert(ctx, []vectorstore.Document{db.Document(t, "a-0000", "a.md", "internal only")}))
+			assert.NilError(t, localstore.Store(db.Store.DB(), options, ctx))
+
+			data, err := os.ReadFile(options.Path)
+			assert.NilError(t, err)
+			assert.Equal(t, bytes.Contains(data, []byte("internal only")), test.plaintext)
+			_, err = os.Stat(localstore.KeyPath(options.Path))
+			assert.Equal(t, err == nil, options.KMSKeyID != "")
+
+			loaded, err := localstore.Load(options, db.Embedder, ctx)
+			assert.NilError(t, err)
+			assert.Equal(t, loaded.GetCollection("knowledge-base", nil).Count(), 1)
+		})
+	}
+
+	// a failed write keeps the file and its key
+	kms := localstore.FileOptions{Path: filepath.Join(dir, "kms.gob"), KMSKeyID: "local:" + masterKeyFile}
+	db := testdb.New(t, 64)
+	assert.NilError(t, localstore.Store(db.Store.DB(), kms, ctx))
+	wrapped, err := os.ReadFile(localstore.KeyPath(kms.Path))
+	assert.NilError(t, err)
+	assert.NilError(t, os.Mkdir(kms.Path+".tmp", 0o755))
+	assert.Assert(t, localstore.Store(db.Store.DB(), kms, ctx) != nil)
+	unchanged, err := os.ReadFile(localstore.KeyPath(kms.Path))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, unchanged, wrapped)
+	_, err = os.Stat(localstore.KeyPath(kms.Path + ".tmp"))
+	assert.Assert(t, os.IsNotExist(err))
+	_, err = localstore.Load(kms, db.Embedder, ctx)
+	assert.NilError(t, err)
+
+	// an encrypted file is not read without the key or with another key
+	options := localstore.FileOptions{Path: filepath.Join(dir, "db.gob"), Key: key}
+	db = testdb.New(t, 64)
+	assert.NilError(t, localstore.Store(db.Store.DB(), options, ctx))
+	_, err = localstore.Load(localstore.FileOptions{Path: options.Path}, db.Embedder, ctx)
+	assert.Assert(t, err != nil)
+	other, err := localstore.GenerateKey()
+	assert.NilError(t, err)
+	_, err = localstore.Load(localstore.FileOptions{Path: options.Path, Key: other}, db.Embedder, ctx)
+	assert.Assert(t, err != nil)
+	_, err = localstore.Load(localstore.FileOptions{Path: options.Path, Key: "c2hvcnQ="}, db.Embedder, ctx)
+	assert.ErrorContains(t, err, "expected 32")
+}
+
+func TestCheckStore(t *testing.T) {
+	tests := []struct {
+		name    string
+		store   string
+		options localstore.FileOptions
+		err     string
+	}{
+		{"chromem", vectorstore.KindChromem, localstore.FileOptions{Compress: true, KMSKeyID: "local:master.key"}, ""},
+		{"sqlite", vectorstore.KindSQLite, localstore.FileOptions{Path: "db.sqlite"}, ""},
+		{"compressed sqlite", vectorstore.KindSQLite, localstore.FileOptions{Compress: true}, "sqlite database cannot be compressed, only chromem"},
+		{"encrypted sqlite", vectorstore.KindSQLite, localstore.FileOptions{KeyFile: "db.key"}, "sqlite database cannot be encrypted, only chromem"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.options.CheckStore(test.store)
+			if test.err == "" {
+				assert.NilError(t, err)
+				return
+			}
+			assert.Error(t, err, test.err)
+		})
+	}
+}
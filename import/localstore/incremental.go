@@ -0,0 +1,97 @@
+package localstore
+
+import (
+	"context"
+	"errors"
+	he "hugoembedding"
+	"hugoembedding/embedding"
+	"hugoembedding/vectorstore"
+	"os"
+	"sort"
+)
+
+// LoadPrevious reads the manifest of the previous import for an
+// incremental import. It returns no manifest if there is none or if the
+// store kind, the embedding model, the chunker settings or the base URL
+// changed, then all files are imported into a new database.
+func LoadPrevious(path string, store string, cfg *he.Config, embedder embedding.Embedder) (*Manifest, error) {
+	manifest, err := ReadManifest(path)
+	return checkPrevious(manifest, err, path, store, cfg, embedder)
+}
+
+// LoadPreviousDB reads the manifest of the previous import from the
+// database like LoadPrevious
+func LoadPreviousDB(db ManifestDB, cfg *he.Config, embedder embedding.Embedder, ctx context.Context) (*Manifest, error) {
+	manifest, err := ReadDBManifest(db, ctx)
+	return checkPrevious(manifest, err, "database", vectorstore.KindPgvector, cfg, embedder)
+}
+
+// checkPrevious returns the manifest if the import can continue the
+// database of the manifest
+func checkPrevious(manifest *Manifest, err error, location string, store string, cfg *he.Config, embedder embedding.Embedder) (*Manifest, error) {
+	log := he.Logger
+	if errors.Is(err, os.ErrNotExist) {
+		log.Info("No previous import, importing all files", "path", location)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// older manifests have no store kind
+	if manifest.Store != store {
+		log.Info("Vector store changed, importing all files", "previous", manifest.Store)
+		return nil, nil
+	}
+	if err := manifest.Check(embedder); err != nil {
+		log.Info("Embedding model changed, importing all files", "reason", err)
+		return nil, nil
+	}
+	if manifest.Chunker != NewManifest(cfg, store, embedder, nil, 0).Chunker {
+		log.Info("Chunker settings changed, importing all files", "previous", manifest.Chunker.Strategy)
+		return nil, nil
+	}
+	if manifest.BaseURL != cfg.BaseURL {
+		log.Info("Base URL changed, importing all files", "previous", manifest.BaseURL)
+		return nil, nil
+	}
+	if manifest.IDScheme != he.IDScheme {
+		log.Info("Document IDs changed, importing all files", "previous", manifest.IDScheme)
+		return nil, nil
+	}
+	return manifest, nil
+}
+
+// Changes are the files of an import compared with the previous import
+type Changes struct {
+	Added     []string
+	Updated   []string
+	Deleted   []string
+	Unchanged []string
+}
+
+// CompareFiles compares the content hashes by path of the previous and
+// the current import
+func CompareFiles(previous map[string]string, current map[string]string) Changes {
+	changes := Changes{}
+	for path, hash := range current {
+		previousHash, found := previous[path]
+		switch {
+		case !found:
+			changes.Added = append(changes.Added, path)
+		case previousHash != hash:
+			changes.Updated = append(changes.Updated, path)
+		default:
+			changes.Unchanged = append(changes.Unchanged, path)
+		}
+	}
+	for path := range previous {
+		if _, found := current[path]; !found {
+			changes.Deleted = append(changes.Deleted, path)
+		}
+	}
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Updated)
+	sort.Strings(changes.Deleted)
+	sort.Strings(changes.Unchanged)
+	return changes
+}
@@ -0,0 +1,93 @@
+package localstore_test
+
+import (
+	"context"
+	he "hugoembedding"
+	"hugoembedding/embedding"
+	"hugoembedding/internal/testdb"
+	"hugoembedding/localstore"
+	"hugoembedding/vectorstore"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCompareFiles(t *testing.T) {
+	previous := map[string]string{"a.md": "1", "b.md": "2", "c.md": "3"}
+	current := map[string]string{"a.md": "1", "b.md": "changed", "d.md": "4"}
+	changes := localstore.CompareFiles(previous, current)
+	assert.DeepEqual(t, changes, localstore.Changes{
+		Added:     []string{"d.md"},
+		Updated:   []string{"b.md"},
+		Deleted:   []string{"c.md"},
+		Unchanged: []string{"a.md"},
+	})
+}
+
+func TestLoadPrevious(t *testing.T) {
+	ctx := context.Background()
+	cfg := he.DefaultConfig()
+	cfg.BaseURL = "https://example.com/"
+	posts := []string{
+		"../testdata/2023/dir-2023-01-31-finding-boot-volumes.md/index.md",
+		"../testdata/2023/dir-2023-01-13-s3-folders.md/index.md",
+	}
+
+	db := testdb.New(t, 64)
+	embedder, path, store := db.Embedder, db.Path, db.Store
+	db.Import(t, cfg, posts...)
+	total, err := store.Count(ctx)
+	assert.NilError(t, err)
+	assert.NilError(t, store.DeleteBySource(ctx, posts[1]))
+	remaining := db.Close(t, cfg, posts[0])
+	assert.Assert(t, remaining > 0 && remaining < total)
+
+	manifest, err := localstore.LoadPrevious(path, vectorstore.KindChromem, cfg, embedder)
+	assert.NilError(t, err)
+	assert.Assert(t, manifest != nil)
+	files, err := localstore.HashFiles(posts[:1])
+	assert.NilError(t, err)
+	assert.DeepEqual(t, manifest.Files, files)
+	previous := db.Load(t)
+	count, err := previous.Count(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, count, remaining)
+	// the IDs are stable
+	document, err := previous.Get(ctx, he.DocumentID(posts[0], 1))
+	assert.NilError(t, err)
+	assert.Assert(t, document != nil)
+	assert.Equal(t, document.Metadata["title"], "Finding EBS Boot Volumes")
+	assert.Equal(t, document.Metadata["link"], "https://example.com/testdata/2023/dir-2023-01-31-finding-boot-volumes.md/")
+	assert.Equal(t, document.Metadata["ordinal"], "1")
+	// the context has the neighbour chunks
+	assert.Assert(t, len(document.Metadata["context"]) > 0)
+	assert.Equal(t, document.Metadata["source"], posts[0])
+
+	// other chunker settings need a new database
+	semantic := he.DefaultConfig()
+	semantic.BaseURL = cfg.BaseURL
+	semantic.Chunker = he.ChunkerSemantic
+	manifest, err = localstore.LoadPrevious(path, vectorstore.KindChromem, semantic, embedder)
+	assert.NilError(t, err)
+	assert.Assert(t, manifest == nil)
+
+	// and other shortcode rules
+	shortcodes := he.DefaultConfig()
+	shortcodes.BaseURL = cfg.BaseURL
+	assert.NilError(t, shortcodes.Shortcodes.Set("notice=drop"))
+	manifest, err = localstore.LoadPrevious(path, vectorstore.KindChromem, shortcodes, embedder)
+	assert.NilError(t, err)
+	assert.Assert(t, manifest == nil)
+
+	// so does another store kind
+	manifest, err = localstore.LoadPrevious(path, vectorstore.KindSQLite, cfg, embedder)
+	assert.NilError(t, err)
+	assert.Assert(t, manifest == nil)
+
+	// and another embedding model
+	other, err := embedding.NewHashing(32)
+	assert.NilError(t, err)
+	manifest, err = localstore.LoadPrevious(path, vectorstore.KindChromem, cfg, other)
+	assert.NilError(t, err)
+	assert.Assert(t, manifest == nil)
+}
@@ -2,21 +2,22 @@ package localstore
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
 
 	"hugoembedding"
+	"hugoembedding/embedding"
 
-	be "github.com/megaproaktiv/bedrockembedding/titan"
 	"github.com/philippgille/chromem-go"
 )
 
-// Init local hugoembedding database
-func Init() (*chromem.DB, error) {
+// Init local hugoembedding database, the embedder embeds the queries
+func Init(embedder embedding.Embedder) (*chromem.DB, error) {
 	log := hugoembedding.Logger
 	db := chromem.NewDB()
 
-	var myEmbeddingFunc chromem.EmbeddingFunc
-	myEmbeddingFunc = MyEmbeddingFunc
-	_, err := db.CreateCollection("knowledge-base", nil, myEmbeddingFunc)
+	_, err := db.CreateCollection("knowledge-base", nil, embedding.Func(embedder))
 	if err != nil {
 		log.Error("Error creating collection", "error", err)
 		return nil, err
@@ -24,21 +25,64 @@ func Init() (*chromem.DB, error) {
 	return db, nil
 }
 
-func Load(path string) (*chromem.DB, error) {
+// Load the database file of the options. The embedder must match the
+// manifest of the database, a database without manifest is loaded with a
+// warning.
+func Load(options FileOptions, embedder embedding.Embedder, ctx context.Context) (*chromem.DB, error) {
 	log := hugoembedding.Logger
+	path := options.Path
+	if err := CheckManifest(path, embedder); err != nil {
+		return nil, err
+	}
+	key, err := options.ReadKey(ctx)
+	if err != nil {
+		log.Error("Error reading encryption key", "error", err)
+		return nil, err
+	}
+
 	db := chromem.NewDB()
 
-	err := db.Import(path, "")
+	err = db.Import(path, key)
 
 	if err != nil {
 		log.Error("Error loading collection", "error", err)
 		return nil, err
 	}
+	// chromem binds an embedding function on the first access of a
+	// loaded collection, without one it would use OpenAI
+	if db.GetCollection("knowledge-base", embedding.Func(embedder)) == nil {
+		return nil, fmt.Errorf("database %v has no knowledge-base collection", path)
+	}
 	return db, nil
 }
 
-// type EmbeddingFunc func(ctx context.Context, text string) ([]float32, error)
-func MyEmbeddingFunc(ctx context.Context, text string) ([]float32, error) {
+// CheckManifest compares the embedder with the manifest of the database
+// file, a database without manifest is accepted with a warning
+func CheckManifest(path string, embedder embedding.Embedder) error {
+	manifest, err := ReadManifest(path)
+	return checkManifest(manifest, err, path, embedder)
+}
+
+// CheckDBManifest compares the embedder with the manifest in the
+// database like CheckManifest
+func CheckDBManifest(db ManifestDB, embedder embedding.Embedder, ctx context.Context) error {
+	manifest, err := ReadDBManifest(db, ctx)
+	return checkManifest(manifest, err, "database", embedder)
+}
 
-	return be.FetchEmbedding(text)
+func checkManifest(manifest *Manifest, err error, path string, embedder embedding.Embedder) error {
+	log := hugoembedding.Logger
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		log.Warn("Database has no manifest, the embedding model is not checked", "path", path)
+	case err != nil:
+		log.Error("Error reading manifest", "error", err)
+		return err
+	default:
+		if err := manifest.Check(embedder); err != nil {
+			log.Error("Embedder does not match the database", "error", err, "path", path)
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,197 @@
+package localstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	he "hugoembedding"
+	"hugoembedding/embedding"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Manifest records how a database was built. It is stored next to the
+// database, a query with another embedding model would compare
+// incompatible vectors.
+type Manifest struct {
+	// Store is the kind of vector store, see vectorstore.Config
+	Store      string          `json:"store,omitempty"`
+	Model      string          `json:"model"`
+	Dimensions int             `json:"dimensions"`
+	Normalize  bool            `json:"normalize"`
+	Chunker    ChunkerManifest `json:"chunker"`
+	// BaseURL of the links in the documents
+	BaseURL    string    `json:"base_url,omitempty"`
+	BuildTime  time.Time `json:"build_time"`
+	CorpusHash string    `json:"corpus_hash"`
+	Documents  int       `json:"documents"`
+	// Files are the content hashes of the imported files by path,
+	// the next import only processes changed files
+	Files map[string]string `json:"files,omitempty"`
+	// IDScheme is the way the document IDs are built
+	IDScheme string `json:"id_scheme,omitempty"`
+}
+
+// ChunkerManifest are the chunking settings of the import
+type ChunkerManifest struct {
+	Strategy             string  `json:"strategy"`
+	TargetTokens         int     `json:"target_tokens,omitempty"`
+	OverlapTokens        int     `json:"overlap_tokens,omitempty"`
+	BreakpointPercentile float64 `json:"breakpoint_percentile,omitempty"`
+	MaxTokens            int     `json:"max_tokens,omitempty"`
+	// Shortcodes are the rules which differ from the built-in rules
+	Shortcodes string `json:"shortcodes,omitempty"`
+}
+
+// NewManifest describes a database of the store kind built with the
+// configuration and embedder from the files
+func NewManifest(cfg *he.Config, store string, embedder embedding.Embedder, files map[string]string, documents int) *Manifest {
+	chunker := ChunkerManifest{Strategy: cfg.Chunker}
+	switch cfg.Chunker {
+	case he.ChunkerSemantic:
+		chunker.BreakpointPercentile = cfg.Semantic.BreakpointPercentile
+		chunker.MaxTokens = cfg.Semantic.MaxTokens
+	default:
+		chunker.Strategy = he.ChunkerStructural
+		chunker.TargetTokens = cfg.Pack.TargetTokens
+		chunker.OverlapTokens = cfg.Pack.OverlapTokens
+	}
+	if rules := cfg.Shortcodes.String(); rules != he.NewShortcodeProcessor().String() {
+		chunker.Shortcodes = rules
+	}
+	return &Manifest{
+		Store:      store,
+		Model:      embedder.Name(),
+		Dimensions: embedder.Dimensions(),
+		Normalize:  cfg.Embedding.Normalize,
+		Chunker:    chunker,
+		BaseURL:    cfg.BaseURL,
+		BuildTime:  time.Now().UTC(),
+		CorpusHash: CorpusHash(files),
+		Documents:  documents,
+		Files:      files,
+		IDScheme:   he.IDScheme,
+	}
+}
+
+// ManifestPath is the manifest of the database file, e.g.
+// db-data/db.gob => db-data/db.manifest.json and
+// db-data/db.sqlite => db-data/db.sqlite.manifest.json
+func ManifestPath(dbPath string) string {
+	return strings.TrimSuffix(dbPath, ".gob") + ".manifest.json"
+}
+
+// WriteManifest stores the manifest of the database file
+func WriteManifest(dbPath string, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(ManifestPath(dbPath), append(data, '\n'), 0o644)
+}
+
+// ReadManifest reads the manifest of the database file,
+// the error wraps os.ErrNotExist if there is none
+func ReadManifest(dbPath string) (*Manifest, error) {
+	data, err := os.ReadFile(ManifestPath(dbPath))
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("decoding manifest %v: %w", ManifestPath(dbPath), err)
+	}
+	return manifest, nil
+}
+
+// ManifestDB keeps the manifest in the database instead of a file next
+// to it, see vectorstore.Postgres
+type ManifestDB interface {
+	// ReadManifest returns the JSON of the manifest, nil if there is none
+	ReadManifest(ctx context.Context) ([]byte, error)
+	WriteManifest(ctx context.Context, manifest []byte) error
+}
+
+// WriteDBManifest stores the manifest in the database
+func WriteDBManifest(db ManifestDB, manifest *Manifest, ctx context.Context) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	return db.WriteManifest(ctx, data)
+}
+
+// ReadDBManifest reads the manifest of the database,
+// the error wraps os.ErrNotExist if there is none
+func ReadDBManifest(db ManifestDB, ctx context.Context) (*Manifest, error) {
+	data, err := db.ReadManifest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, fmt.Errorf("database has no manifest: %w", os.ErrNotExist)
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("decoding manifest of the database: %w", err)
+	}
+	return manifest, nil
+}
+
+// ErrEmbedderMismatch is returned if the database was built with
+// another embedding model
+var ErrEmbedderMismatch = errors.New("embedder does not match the database")
+
+// Check verifies that the embedder creates vectors which are comparable
+// with the database. The normalization is not checked, chromem normalizes
+// all vectors before comparing them.
+func (m *Manifest) Check(embedder embedding.Embedder) error {
+	if m.Model != embedder.Name() {
+		return fmt.Errorf("%w: database built with %v, query uses %v", ErrEmbedderMismatch, m.Model, embedder.Name())
+	}
+	dimensions := embedder.Dimensions()
+	if m.Dimensions != 0 && dimensions != 0 && m.Dimensions != dimensions {
+		return fmt.Errorf("%w: database has %d dimensions, %v returns %d", ErrEmbedderMismatch, m.Dimensions, embedder.Name(), dimensions)
+	}
+	return nil
+}
+
+// HashFiles returns the content hash of each file by path
+func HashFiles(paths []string) (map[string]string, error) {
+	files := make(map[string]string, len(paths))
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		content := sha256.New()
+		_, err = io.Copy(content, file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		files[filepath.ToSlash(path)] = hex.EncodeToString(content.Sum(nil))
+	}
+	return files, nil
+}
+
+// CorpusHash identifies the content of the imported files
+func CorpusHash(files map[string]string) string {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	corpus := sha256.New()
+	for _, path := range paths {
+		fmt.Fprintf(corpus, "%v\x00%v\n", path, files[path])
+	}
+	return hex.EncodeToString(corpus.Sum(nil))
+}
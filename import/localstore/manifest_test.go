@@ -0,0 +1,127 @@
+package localstore_test
+
+import (
+	"context"
+	he "hugoembedding"
+	"hugoembedding/localstore"
+	"hugoembedding/vectorstore"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+// fixedEmbedder reports a model without calling it
+type fixedEmbedder struct {
+	name       string
+	dimensions int
+}
+
+func (f fixedEmbedder) Name() string    { return f.name }
+func (f fixedEmbedder) Dimensions() int { return f.dimensions }
+func (f fixedEmbedder) MaxInput() int   { return 0 }
+func (f fixedEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	return make([][]float32, len(texts)), nil
+}
+
+func TestManifest(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "db.gob")
+	titan := fixedEmbedder{name: "bedrock/amazon.titan-embed-text-v1", dimensions: 1536}
+	db, err := localstore.Init(titan)
+	assert.NilError(t, err)
+	assert.NilError(t, db.Export(dbPath, false, ""))
+	cfg := he.DefaultConfig()
+
+	// without manifest the database is loaded
+	_, err = localstore.Load(localstore.FileOptions{Path: dbPath}, titan, ctx)
+	assert.NilError(t, err)
+
+	manifest := localstore.NewManifest(cfg, vectorstore.KindChromem, titan, map[string]string{"a.md": "abc"}, 3)
+	assert.NilError(t, localstore.WriteManifest(dbPath, manifest))
+	assert.Equal(t, localstore.ManifestPath(dbPath), filepath.Join(dir, "db.manifest.json"))
+	// a SQLite database beside the file has its own manifest
+	assert.Equal(t, localstore.ManifestPath(filepath.Join(dir, "db.sqlite")), filepath.Join(dir, "db.sqlite.manifest.json"))
+
+	read, err := localstore.ReadManifest(dbPath)
+	assert.NilError(t, err)
+	assert.Equal(t, read.Store, vectorstore.KindChromem)
+	assert.Equal(t, read.Model, titan.name)
+	assert.Equal(t, read.Dimensions, 1536)
+	assert.Equal(t, read.Chunker.Strategy, he.ChunkerStructural)
+	assert.Equal(t, read.Chunker.TargetTokens, cfg.Pack.TargetTokens)
+	assert.Equal(t, read.Documents, 3)
+
+	_, err = localstore.Load(localstore.FileOptions{Path: dbPath}, titan, ctx)
+	assert.NilError(t, err)
+
+	_, err = localstore.Load(localstore.FileOptions{Path: dbPath}, fixedEmbedder{name: "bedrock/amazon.titan-embed-text-v2:0", dimensions: 1024}, ctx)
+	assert.ErrorIs(t, err, localstore.ErrEmbedderMismatch)
+	assert.ErrorContains(t, err, "amazon.titan-embed-text-v1")
+
+	_, err = localstore.Load(localstore.FileOptions{Path: dbPath}, fixedEmbedder{name: titan.name, dimensions: 512}, ctx)
+	assert.ErrorContains(t, err, "1536 dimensions")
+}
+
+// memoryManifest is a database which keeps the manifest
+type memoryManifest struct {
+	data []byte
+}
+
+func (m *memoryManifest) ReadManifest(ctx context.Context) ([]byte, error) {
+	return m.data, nil
+}
+
+func (m *memoryManifest) WriteManifest(ctx context.Context, manifest []byte) error {
+	m.data = manifest
+	return nil
+}
+
+func TestDBManifest(t *testing.T) {
+	ctx := context.Background()
+	titan := fixedEmbedder{name: "bedrock/amazon.titan-embed-text-v1", dimensions: 1536}
+	cfg := he.DefaultConfig()
+	db := &memoryManifest{}
+
+	_, err := localstore.ReadDBManifest(db, ctx)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	previous, err := localstore.LoadPreviousDB(db, cfg, titan, ctx)
+	assert.NilError(t, err)
+	assert.Assert(t, previous == nil)
+
+	files := map[string]string{"a.md": "abc"}
+	assert.NilError(t, localstore.WriteDBManifest(db, localstore.NewManifest(cfg, vectorstore.KindPgvector, titan, files, 3), ctx))
+	previous, err = localstore.LoadPreviousDB(db, cfg, titan, ctx)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, previous.Files, files)
+
+	// another embedding model imports all files
+	previous, err = localstore.LoadPreviousDB(db, cfg, fixedEmbedder{name: "openai/text-embedding-3-small"}, ctx)
+	assert.NilError(t, err)
+	assert.Assert(t, previous == nil)
+}
+
+func TestCorpusHash(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.md")
+	b := filepath.Join(dir, "b.md")
+	assert.NilError(t, os.WriteFile(a, []byte("a"), 0o644))
+	assert.NilError(t, os.WriteFile(b, []byte("b"), 0o644))
+
+	files, err := localstore.HashFiles([]string{a, b})
+	assert.NilError(t, err)
+	assert.Equal(t, len(files), 2)
+	reordered, err := localstore.HashFiles([]string{b, a})
+	assert.NilError(t, err)
+	hash := localstore.CorpusHash(files)
+	assert.Equal(t, hash, localstore.CorpusHash(reordered))
+
+	assert.NilError(t, os.WriteFile(b, []byte("changed"), 0o644))
+	changed, err := localstore.HashFiles([]string{a, b})
+	assert.NilError(t, err)
+	assert.Equal(t, changed[filepath.ToSlash(a)], files[filepath.ToSlash(a)])
+	assert.Assert(t, changed[filepath.ToSlash(b)] != files[filepath.ToSlash(b)])
+	assert.Assert(t, hash != localstore.CorpusHash(changed))
+}
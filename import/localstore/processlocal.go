@@ -2,92 +2,137 @@ package localstore
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	he "hugoembedding"
+	"hugoembedding/embedding"
+	"hugoembedding/vectorstore"
 	"os"
-	"runtime"
+	"path/filepath"
 	"strconv"
-
-	be "github.com/megaproaktiv/bedrockembedding/titan"
-	"github.com/philippgille/chromem-go"
+	"strings"
 )
 
-var IDCount int
-
-func init() {
-	IDCount = 0
-}
+// ContextNeighbours is the number of chunks before and after a chunk in
+// its context
+const ContextNeighbours = 1
 
-// Call process and import into embedding
-func ProcessIndex(path string, conversionMethod int, db *chromem.DB, ctx context.Context) error {
+// Call process and import into the vector store
+func ProcessIndex(path string, conversionMethod int, cfg *he.Config, embedder embedding.Embedder, store vectorstore.VectorStore, ctx context.Context) error {
 	log := he.Logger
 
 	log.Info("Processing Index", "path", path)
 
-	collection := db.GetCollection("knowledge-base", nil)
-
 	// Get chunks from file
 	markdownFileContent, err := os.ReadFile(path)
-	chunks, err := he.Parse(markdownFileContent)
+	if err != nil {
+		log.Error("Error reading markdown file", "error", err)
+		return err
+	}
+	chunks, err := he.Parse(markdownFileContent, cfg)
 	if err != nil {
 		log.Error("Error parsing markdown file", "error", err)
 		return err
 	}
 
-	// Compress Chunks
-	chunks, err = he.CompressChunks(chunks, 300)
+	// Combine Chunks
+	chunks, err = he.ChunkDocument(chunks, cfg, embedding.Func(embedder), ctx)
 	if err != nil {
-		log.Error("Error compressing chunks", "error", err)
+		log.Error("Error combining chunks", "error", err)
 		return err
 	}
 	// Get Metadata
-	meta, err := he.ExtractMetadata(path)
-	title := ""
-	link := ""
-	if meta != nil {
-		title = meta.Title
-	}
+	meta, err := he.ParseMetadata(markdownFileContent)
 	if err != nil {
 		log.Error("Metadata extraction problem:", "error", err, "file", path)
+		meta = &he.Metadata{}
 	}
-	// Put chunks into database
+	title := meta.Title
+	pageLink := he.Path2Link(path, conversionMethod, meta.Date)
+	link := he.ResolveLink(cfg.BaseURL, pageLink, "")
+	// the heading path is embedded and stored with the chunk
+	texts := make([]string, len(*chunks))
 	for i, chunk := range *chunks {
-		content := chunk.Chunk
-
-		context := content
-		c := *chunks
-		if i > 0 && i < len(c)-1 {
-			a := *(c[i-1].Chunk)
-			b := *(c[i].Chunk)
-			c := *(c[i+1].Chunk)
-			cs := (a + b + c)
-			context = &cs
+		texts[i] = chunk.EmbeddingText()
+	}
+	embeddings, err := embedder.EmbedBatch(ctx, texts)
+	// chunks which failed after the retries are skipped, the others are stored
+	var batchError *embedding.BatchError
+	if err != nil && !errors.As(err, &batchError) {
+		log.Error("Error embedding chunks", "error", err, "embedder", embedder.Name())
+		return err
+	}
+	// Put chunks into database, the documents of a file are stored at once
+	documents := make([]vectorstore.Document, 0, len(*chunks))
+	for i, chunk := range *chunks {
+		if embeddings[i] == nil {
+			log.Error("Chunk not embedded", "file", path, "chunk", i, "embedder", embedder.Name())
+			continue
 		}
+		content := &texts[i]
+
+		// the neighbours give the query more context than the chunk
+		context := NeighbourContext(*chunks, i, ContextNeighbours)
 
-		id := strconv.Itoa(IDCount)
-		IDCount++
+		// the ID is stable across imports
+		id := he.DocumentID(path, i)
 
 		metaData := map[string]string{
-			"link":  link,
-			"title": title,
+			"link":         link,
+			"title":        title,
+			"author":       meta.Author,
+			"date":         meta.Date,
+			"language":     meta.Language,
+			"tags":         strings.Join(meta.Tags, he.ListSeparator),
+			"categories":   strings.Join(meta.Categories, he.ListSeparator),
+			"heading_path": chunk.HeadingPath,
+			"ordinal":      strconv.Itoa(i),
+			"context":      context,
+			"links":        strings.Join(he.ResolveLinks(cfg.BaseURL, pageLink, chunk.Links), he.LinkSeparator),
+			"kind":         string(chunk.Kind),
+			// the incremental import deletes the documents by source
+			"source": filepath.ToSlash(path),
 		}
-		log.Info("Adding document into chromem", "count", id, "content", *content, "link", link, "title", title)
-		singleEmbedding, err := be.FetchEmbedding(*content)
-		// ***** ID Must be unique *****
-		// Other wise documents will be overwritten
-		err = collection.AddDocuments(ctx, []chromem.Document{
-			{
-				ID:        id,
-				Content:   *content,
-				Embedding: singleEmbedding,
-				Metadata:  metaData,
-			},
-		}, runtime.NumCPU())
-		if err != nil {
-			panic(err)
+		if chunk.Image != "" {
+			metaData["image"] = he.ResolveLink(cfg.BaseURL, pageLink, chunk.Image)
 		}
+		if chunk.Language != "" {
+			metaData["code_language"] = chunk.Language
+		}
+		log.Info("Adding document into vector store", "count", id, "content", *content, "link", link, "title", title)
+		// ***** ID Must be unique *****
+		// Other wise documents will be overwritten, the documents of a
+		// changed file are deleted before it is imported again
+		documents = append(documents, vectorstore.Document{
+			ID:        id,
+			Content:   *content,
+			Embedding: embeddings[i],
+			Metadata:  metaData,
+		})
 
 		fmt.Printf("Cluster %v: {%v}\n / [%v]\n", i+1, content, context)
 	}
+	if err := store.Upsert(ctx, documents); err != nil {
+		log.Error("Error adding documents", "error", err, "file", path)
+		return err
+	}
+	if batchError != nil {
+		return batchError
+	}
 	return nil
 }
+
+// NeighbourContext is the text of the chunk with up to n chunks before and
+// after it, the first and the last chunk have neighbours on one side only
+func NeighbourContext(chunks []he.Chunk, i int, n int) string {
+	var joined strings.Builder
+	for j := max(0, i-n); j <= min(len(chunks)-1, i+n); j++ {
+		text := *chunks[j].Chunk
+		// each markdown element starts on a new line
+		if joined.Len() > 0 && !strings.HasSuffix(joined.String(), "\n") {
+			joined.WriteString("\n")
+		}
+		joined.WriteString(text)
+	}
+	return joined.String()
+}
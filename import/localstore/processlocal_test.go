@@ -0,0 +1,38 @@
+package localstore_test
+
+import (
+	he "hugoembedding"
+	"hugoembedding/localstore"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"gotest.tools/v3/assert"
+)
+
+func TestNeighbourContext(t *testing.T) {
+	chunks := []he.Chunk{
+		{Chunk: aws.String("First.\n")},
+		{Chunk: aws.String(" - a list")},
+		{Chunk: aws.String("Third.\n")},
+		{Chunk: aws.String("Last.\n")},
+	}
+	tests := []struct {
+		name       string
+		chunks     []he.Chunk
+		i          int
+		neighbours int
+		context    string
+	}{
+		{"first", chunks, 0, 1, "First.\n - a list"},
+		{"middle", chunks, 1, 1, "First.\n - a list\nThird.\n"},
+		{"last", chunks, 3, 1, "Third.\nLast.\n"},
+		{"window beyond both ends", chunks, 1, 5, "First.\n - a list\nThird.\nLast.\n"},
+		{"no neighbours", chunks, 2, 0, "Third.\n"},
+		{"single chunk", chunks[:1], 0, 1, "First.\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, localstore.NeighbourContext(test.chunks, test.i, test.neighbours), test.context)
+		})
+	}
+}
@@ -2,58 +2,57 @@ package localstore_test
 
 import (
 	"context"
-	"hugoembedding/localstore"
+	he "hugoembedding"
+	"hugoembedding/embedding"
+	"hugoembedding/internal/testdb"
 	"testing"
 
-	be "github.com/megaproaktiv/bedrockembedding/titan"
-	"github.com/philippgille/chromem-go"
 	"gotest.tools/v3/assert"
 )
 
-// "os"
+// TestSimpleQuery imports posts with the offline embedder and queries them
 func TestSimpleQuery(t *testing.T) {
 	// Setup
-	db := chromem.NewDB()
-	path := "testdata/db.gob"
-	t.Log("Using local test db ", path)
-	db, err := localstore.Load(path)
-	assert.NilError(t, err)
-
-	// Test
 	ctx := context.Background()
-	var myEmbeddingFunc chromem.EmbeddingFunc
-	myEmbeddingFunc = MyEmbeddingFunc
+	cfg := he.DefaultConfig()
+	cfg.Embedding.Provider = embedding.ProviderHashing
+	db := testdb.New(t, 0)
+	embedder := db.Embedder
+	posts := []string{
+		"../testdata/2023/dir-2023-01-31-finding-boot-volumes.md/index.md",
+		"../testdata/2023/dir-2023-01-13-s3-folders.md/index.md",
+		"../testdata/2023/dir-2023-12-23-kafka-schema-evolution.md/index.md",
+		"../testdata/2023/dir-2023-09-20-access-your-vpc-with-client-vpn.md/index.md",
+		"../testdata/2023/dir-2023-08-04-scaling-down-eks-clusters-at-night.md/index.md",
+	}
+	db.Import(t, cfg, posts...)
 
-	c := db.GetCollection("knowledge-base", myEmbeddingFunc)
-	t.Logf("Collection initialized, count documents: %v\n", c.Count())
-	questions := [...]string{
-		"Container on ECS ec2 does not start?",
-		"How do i generate a presigned URL?",
-		"What duration has a presigned URL?",
-		"What tipps for tuning ZED editor do you have?",
+	// Store and load with the manifest
+	documents := db.Close(t, cfg, posts...)
+	store := db.Load(t)
+
+	// Test
+	t.Logf("Collection initialized, count documents: %v\n", documents)
+	questions := []struct {
+		question string
+		title    string
+	}{
+		{"How do I find the EBS boot volumes of my instances?", "Finding EBS Boot Volumes"},
+		{"Are there folders in S3 buckets?", "What are the folders in the S3 console?"},
+		{"How does the Glue schema registry handle Kafka schema evolution?", "Streamlined Kafka Schema Evolution in AWS using MSK and the Glue Schema Registry"},
+		{"Scale down EKS clusters at night", "Scaling Down EKS Clusters at night"},
 	}
 	for _, q := range questions {
-
-		t.Logf("Question: %v\n", q)
-		res, err := c.Query(ctx, q, 10, nil, nil)
-		if err != nil {
-			panic(err)
-		}
-		t.Logf("Output has size %v\n", len(res))
-		verbose := true
+		t.Logf("Question: %v\n", q.question)
+		question, err := embedding.Embed(ctx, embedder, q.question)
+		assert.NilError(t, err)
+		res, err := store.Query(ctx, question, 3, nil)
+		assert.NilError(t, err)
+		titles := []string{}
 		for _, r := range res {
-			if verbose {
-				t.Logf("ID: %v - Similarity: %2.2f / Title: %v\n ===\n %v\n ====\n", r.ID, r.Similarity, r.Metadata["title"], r.Content)
-			} else {
-				t.Logf("ID: %v - Similarity: %2.2f / Title: %v\n", r.ID, r.Similarity, r.Metadata["title"])
-
-			}
+			t.Logf("ID: %v - Similarity: %2.2f / Title: %v\n", r.ID, r.Similarity, r.Metadata["title"])
+			titles = append(titles, r.Metadata["title"])
 		}
+		assert.Equal(t, titles[0], q.title, "question %q found %v", q.question, titles)
 	}
-
-}
-
-func MyEmbeddingFunc(ctx context.Context, text string) ([]float32, error) {
-
-	return be.FetchEmbedding(text)
 }
@@ -1,15 +1,17 @@
 package localstore
 
-import (
-	"hugoembedding"
-
-	"github.com/philippgille/chromem-go"
-)
-
-// Store Database
-func Store(db *chromem.DB) error {
-	log := hugoembedding.Logger
-	const path = "db-data/db.gob"
-	log.Info("Storing Database", "path", path)
-	return db.Export(path, false, "")
+import "hugoembedding/vectorstore"
+
+// DBPath is the database file of the import
+const DBPath = "db-data/db.gob"
+
+// SQLitePath is the database file of an import into SQLite
+const SQLitePath = "db-data/db.sqlite"
+
+// DefaultDBPath is the database file of the store kind
+func DefaultDBPath(store string) string {
+	if store == vectorstore.KindSQLite {
+		return SQLitePath
+	}
+	return DBPath
 }
@@ -2,17 +2,20 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	he "hugoembedding"
+	"hugoembedding/embedding"
 	"hugoembedding/localstore"
+	"hugoembedding/vectorstore"
 	"log/slog"
 	"os"
 	"path/filepath"
 
-	"github.com/jackc/pgx/v5"
+	"github.com/philippgille/chromem-go"
 )
 
-var Connection *pgx.Conn
 var ctx context.Context
 var Log *slog.Logger
 
@@ -26,18 +29,107 @@ func init() {
 
 }
 
-const Version = 1
+// Version of the importer, incremented with changes of the stored documents
+const Version = 2
 
 func main() {
+	cfg := he.DefaultConfig()
+	cfg.Embedding = embedding.ConfigFromEnv(cfg.Embedding)
+	flag.StringVar(&cfg.Embedding.Provider, "embedding-provider", cfg.Embedding.Provider, "Embedding provider: titan-v1, titan-v2, cohere, openai, ollama or hashing")
+	flag.StringVar(&cfg.Embedding.Model, "embedding-model", cfg.Embedding.Model, "Embedding model, empty for the default of the provider")
+	flag.IntVar(&cfg.Embedding.Dimensions, "embedding-dimensions", cfg.Embedding.Dimensions, "Embedding dimensions of titan-v2 and openai v3 models")
+	flag.BoolVar(&cfg.Embedding.Normalize, "embedding-normalize", cfg.Embedding.Normalize, "Normalize titan-v2 embeddings")
+	flag.StringVar(&cfg.Embedding.Endpoint, "embedding-endpoint", cfg.Embedding.Endpoint, "Base URL of an OpenAI compatible embedding API")
+	flag.StringVar(&cfg.BaseURL, "base-url", cfg.BaseURL, "Base URL of the site, e.g. https://example.com/, for the links of the documents")
+	flag.StringVar(&cfg.Chunker, "chunker", cfg.Chunker, "Chunking strategy: structural or semantic")
+	flag.Float64Var(&cfg.Semantic.BreakpointPercentile, "semantic-percentile", cfg.Semantic.BreakpointPercentile, "Semantic chunker: percentile of neighbour similarity which starts a new chunk")
+	flag.IntVar(&cfg.Semantic.MaxTokens, "semantic-max-tokens", cfg.Semantic.MaxTokens, "Semantic chunker: maximum size of a chunk in estimated tokens")
+	flag.IntVar(&cfg.Pack.TargetTokens, "chunk-tokens", cfg.Pack.TargetTokens, "Target size of a chunk in estimated tokens")
+	flag.IntVar(&cfg.Pack.OverlapTokens, "chunk-overlap", cfg.Pack.OverlapTokens, "Tokens of the previous chunk repeated in the next chunk")
+	flag.Var(cfg.Shortcodes, "shortcode", "Rule of a Hugo shortcode, e.g. notice=drop or button=attribute:text, * for unknown shortcodes, can be repeated")
+	flag.IntVar(&cfg.Scheduler.Workers, "embedding-workers", cfg.Scheduler.Workers, "Concurrent embedding requests")
+	flag.Float64Var(&cfg.Scheduler.RequestsPerSecond, "embedding-rps", cfg.Scheduler.RequestsPerSecond, "Maximum embedding requests per second, 0 is unlimited")
+	flag.IntVar(&cfg.Scheduler.MaxRetries, "embedding-retries", cfg.Scheduler.MaxRetries, "Retries of a throttled or failed embedding request")
+	cacheDir := flag.String("cache-dir", "embedding-cache", "Directory of the embedding cache, empty disables the cache")
+	cachePrune := flag.Bool("cache-prune", false, "Remove cached embeddings which were not used by this import, needs -full")
+	full := flag.Bool("full", false, "Import all files into a new database instead of the changed files")
+	storeCfg := vectorstore.ConfigFromEnv(vectorstore.DefaultConfig())
+	flag.StringVar(&storeCfg.Kind, "vector-store", storeCfg.Kind, "Vector store: chromem or pgvector")
+	flag.StringVar(&storeCfg.DatabaseURL, "database-url", storeCfg.DatabaseURL, "Postgres connection string of pgvector")
+	flag.StringVar(&storeCfg.Postgres.Table, "pg-table", storeCfg.Postgres.Table, "pgvector: table of the documents")
+	flag.IntVar(&storeCfg.Postgres.Dimensions, "pg-dimensions", storeCfg.Postgres.Dimensions, "pgvector: dimensions of the embedding column, 0 for the dimensions of the embedder")
+	flag.StringVar(&storeCfg.Postgres.Index, "pg-index", storeCfg.Postgres.Index, "pgvector: vector index hnsw, ivfflat or none")
+	flag.IntVar(&storeCfg.Postgres.M, "pg-hnsw-m", storeCfg.Postgres.M, "pgvector: connections per node of the HNSW index")
+	flag.IntVar(&storeCfg.Postgres.EfConstruction, "pg-hnsw-ef-construction", storeCfg.Postgres.EfConstruction, "pgvector: candidate list size while building the HNSW index")
+	flag.IntVar(&storeCfg.Postgres.Lists, "pg-ivfflat-lists", storeCfg.Postgres.Lists, "pgvector: clusters of the IVFFlat index, 0 derives them from the number of documents")
+	flag.IntVar(&storeCfg.Postgres.BatchSize, "pg-batch", storeCfg.Postgres.BatchSize, "pgvector: documents copied at once")
+	flag.StringVar(&storeCfg.SQLite.Encoding, "sqlite-encoding", storeCfg.SQLite.Encoding, "sqlite: vector encoding float32 or int8")
+	// the default database file depends on the store kind
+	file := localstore.FileOptionsFromEnv(localstore.FileOptions{})
+	flag.StringVar(&file.Path, "db-path", file.Path, "chromem and sqlite: database file, default "+localstore.DBPath+" or "+localstore.SQLitePath)
+	flag.BoolVar(&file.Compress, "db-compress", file.Compress, "chromem: compress the database file with gzip")
+	flag.StringVar(&file.KeyFile, "db-key-file", file.KeyFile, "chromem: file with the base64 AES key which encrypts the database, DB_ENCRYPTION_KEY is the key itself")
+	flag.StringVar(&file.KMSKeyID, "db-kms-key-id", file.KMSKeyID, "chromem: KMS key which wraps the data key of the database, local:<master key file> for a local stand-in")
+	version := flag.Bool("version", false, "Print the version and exit")
+	flag.Parse()
+	if *version {
+		fmt.Printf("import %v, pgvector schema %v\n", Version, vectorstore.SchemaVersion)
+		return
+	}
+	// an incremental import does not embed the unchanged files, their
+	// cached embeddings would be removed
+	if *cachePrune && !*full {
+		fmt.Println("-cache-prune needs -full")
+		os.Exit(2)
+	}
+	if file.Path == "" {
+		file.Path = localstore.DefaultDBPath(storeCfg.Kind)
+	}
+	if err := file.CheckStore(storeCfg.Kind); err != nil {
+		fmt.Println("Error in database options:", err)
+		os.Exit(2)
+	}
+	he.Logger.Info("Import started", "version", Version, "store", storeCfg.Kind)
 
 	directoryPath := "./testdata"
 
-	db, err := localstore.Init()
+	embedder, err := embedding.New(cfg.Embedding)
+	if err != nil {
+		fmt.Println("Error creating embedder:", err)
+		os.Exit(1)
+	}
+	// requests are retried and limited, a throttle does not stop the import
+	scheduler := embedding.NewScheduler(embedder, cfg.Scheduler)
+	embedder = scheduler
+	// unchanged chunks are not embedded again
+	var cache *embedding.Cache
+	if *cacheDir != "" {
+		cache, err = embedding.NewCache(embedder, *cacheDir)
+		if err != nil {
+			fmt.Println("Error creating embedding cache:", err)
+			os.Exit(1)
+		}
+		embedder = cache
+	}
 	ctx := context.Background()
-
+	// the manifest is written next to the database file
+	dbPath := file.Path
+	// only added, updated and deleted files of the previous import are processed
+	var previous *localstore.Manifest
+	if !*full {
+		previous, err = loadPrevious(storeCfg, dbPath, cfg, embedder, ctx)
+		if err != nil {
+			fmt.Println("Error loading previous import:", err)
+			os.Exit(1)
+		}
+	}
+	store, err := openStore(storeCfg, file, embedder, previous == nil, ctx)
 	if err != nil {
-		fmt.Println("Error creating collection:", err)
+		fmt.Println("Error opening vector store:", err)
+		os.Exit(1)
 	}
+
+	var paths []string
 	err = filepath.Walk(directoryPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -46,15 +138,187 @@ func main() {
 			"path", path,
 			"name", info.Name())
 
-		fileName := info.Name()
-		if fileName == "index.md" {
-			localstore.ProcessIndex(path, 1, db, ctx)
+		if info.Name() == "index.md" {
+			paths = append(paths, path)
 		}
-		localstore.Store(db)
 		return nil
 	})
 	if err != nil {
 		fmt.Println("Error walking directory:", err)
+		os.Exit(1)
+	}
+	files, err := localstore.HashFiles(paths)
+	if err != nil {
+		fmt.Println("Error hashing files:", err)
+		os.Exit(1)
 	}
+	previousFiles := map[string]string{}
+	if previous != nil {
+		previousFiles = previous.Files
+	}
+	changes := localstore.CompareFiles(previousFiles, files)
 
+	failed := 0
+	for _, path := range changes.Deleted {
+		if err := store.DeleteBySource(ctx, path); err != nil {
+			he.Logger.Error("Error deleting documents", "error", err, "path", path)
+			// the manifest keeps the file, the next run deletes it again
+			files[path] = previousFiles[path]
+			failed++
+		}
+	}
+	for _, path := range append(changes.Added, changes.Updated...) {
+		// remove the documents of the previous version or of a failed import
+		err := store.DeleteBySource(ctx, path)
+		if err == nil {
+			err = localstore.ProcessIndex(path, 1, cfg, embedder, store, ctx)
+		}
+		if err != nil {
+			he.Logger.Error("Error importing file", "error", err, "path", path)
+			// without hash the file is imported again by the next run
+			delete(files, path)
+			failed++
+		}
+	}
+	// an IVFFlat index is built from the imported documents
+	if postgres, ok := store.(*vectorstore.Postgres); ok {
+		if err := postgres.CreateIndex(ctx); err != nil {
+			fmt.Println("Error creating vector index:", err)
+		}
+	}
+	documents, err := store.Count(ctx)
+	if err != nil {
+		fmt.Println("Error counting documents:", err)
+	}
+	// the manifest records the embedding model for the query, pgvector
+	// keeps it in the database
+	manifest := localstore.NewManifest(cfg, storeCfg.Kind, embedder, files, documents)
+	if manifest.Dimensions == 0 {
+		// the migration of a chromem database reads them from the manifest
+		if manifest.Dimensions, err = embeddingDimensions(embedder, ctx); err != nil {
+			fmt.Println("Error reading embedding dimensions:", err)
+		}
+	}
+	if postgres, ok := store.(*vectorstore.Postgres); ok {
+		if err := localstore.WriteDBManifest(postgres, manifest, ctx); err != nil {
+			fmt.Println("Error writing manifest:", err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		fmt.Println("Error storing database:", err)
+		os.Exit(1)
+	}
+	if storeCfg.Kind != vectorstore.KindPgvector {
+		if err := localstore.WriteManifest(dbPath, manifest); err != nil {
+			fmt.Println("Error writing manifest:", err)
+		}
+	}
+	he.Logger.Info("Import finished",
+		"added", len(changes.Added),
+		"updated", len(changes.Updated),
+		"deleted", len(changes.Deleted),
+		"unchanged", len(changes.Unchanged),
+		"failed", failed,
+		"documents", documents)
+
+	for _, failure := range scheduler.Failures() {
+		he.Logger.Error("Embedding failed", "error", failure.Err, "text", failure.Text[:min(64, len(failure.Text))])
+	}
+	if cache != nil {
+		stats := cache.Stats()
+		he.Logger.Info("Embedding cache", "hits", stats.Hits, "misses", stats.Misses)
+		if *cachePrune {
+			removed, err := cache.Prune()
+			if err != nil {
+				fmt.Println("Error pruning embedding cache:", err)
+			}
+			he.Logger.Info("Embedding cache pruned", "removed", removed)
+		}
+	}
+
+}
+
+// openStore opens the configured vector store, a fresh store has none of
+// the documents of a previous import
+func openStore(cfg vectorstore.Config, file localstore.FileOptions, embedder embedding.Embedder, fresh bool, ctx context.Context) (vectorstore.VectorStore, error) {
+	switch cfg.Kind {
+	case vectorstore.KindPgvector:
+		if cfg.Postgres.Dimensions == 0 {
+			dimensions, err := embeddingDimensions(embedder, ctx)
+			if err != nil {
+				return nil, err
+			}
+			cfg.Postgres.Dimensions = dimensions
+		}
+		store, err := vectorstore.ConnectPostgres(cfg.DatabaseURL, cfg.Postgres, ctx)
+		if err != nil {
+			return nil, err
+		}
+		err = store.Migrate(ctx)
+		switch {
+		case fresh && errors.Is(err, vectorstore.ErrDimensionMismatch):
+			// another embedding model, the table is created again
+			err = store.Drop(ctx)
+			if err == nil {
+				err = store.Migrate(ctx)
+			}
+		case fresh && err == nil:
+			err = store.Truncate(ctx)
+		}
+		if err != nil {
+			store.Close()
+			return nil, err
+		}
+		return store, nil
+	case vectorstore.KindChromem:
+		var db *chromem.DB
+		var err error
+		if fresh {
+			db, err = localstore.Init(embedder)
+		} else {
+			db, err = localstore.Load(file, embedder, ctx)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return vectorstore.NewChromem(db, localstore.StoreFunc(file, ctx))
+	case vectorstore.KindSQLite:
+		if fresh {
+			if err := os.Remove(file.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return nil, err
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(file.Path), 0o755); err != nil {
+			return nil, err
+		}
+		return vectorstore.OpenSQLite(file.Path, cfg.SQLite, ctx)
+	}
+	return nil, fmt.Errorf("unknown vector store %q", cfg.Kind)
+}
+
+// loadPrevious reads the manifest of the previous import, the manifest
+// of pgvector is in the database
+func loadPrevious(storeCfg vectorstore.Config, dbPath string, cfg *he.Config, embedder embedding.Embedder, ctx context.Context) (*localstore.Manifest, error) {
+	if storeCfg.Kind != vectorstore.KindPgvector {
+		return localstore.LoadPrevious(dbPath, storeCfg.Kind, cfg, embedder)
+	}
+	store, err := vectorstore.ConnectPostgres(storeCfg.DatabaseURL, storeCfg.Postgres, ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	return localstore.LoadPreviousDB(store, cfg, embedder, ctx)
+}
+
+// embeddingDimensions asks the embedder for the size of its vectors,
+// some providers only know it after the first request
+func embeddingDimensions(embedder embedding.Embedder, ctx context.Context) (int, error) {
+	if dimensions := embedder.Dimensions(); dimensions > 0 {
+		return dimensions, nil
+	}
+	vector, err := embedding.Embed(ctx, embedder, "dimensions")
+	if err != nil {
+		return 0, err
+	}
+	return len(vector), nil
 }
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	he "hugoembedding"
+	"hugoembedding/localstore"
+	"hugoembedding/vectorstore"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func init() {
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+}
+
+// kindJSONL is the JSONL export, it is no vector store of the lambda
+const kindJSONL = "jsonl"
+
+func main() {
+	storeCfg := vectorstore.ConfigFromEnv(vectorstore.DefaultConfig())
+	from := flag.String("from", "chromem:"+localstore.DBPath, "Source store as kind:location, e.g. chromem:db-data/db.gob, sqlite:db-data/db.sqlite, jsonl:export.jsonl or pgvector:postgres://...#table")
+	to := flag.String("to", "", "Target store as kind:location, pgvector without location uses DATABASE_URL, without #table the table of -pg-table")
+	replace := flag.Bool("replace", false, "Replace the documents of an existing target")
+	batch := flag.Int("batch", vectorstore.DefaultMigrateBatch, "Documents written at once")
+	flag.StringVar(&storeCfg.Postgres.Table, "pg-table", storeCfg.Postgres.Table, "pgvector: table of the documents of a location without #table")
+	flag.StringVar(&storeCfg.Postgres.Index, "pg-index", storeCfg.Postgres.Index, "pgvector: vector index of the target, hnsw, ivfflat or none")
+	flag.StringVar(&storeCfg.SQLite.Encoding, "sqlite-encoding", storeCfg.SQLite.Encoding, "sqlite: vector encoding of the target, float32 or int8")
+	flag.Parse()
+	if *to == "" {
+		fmt.Println("Missing -to")
+		os.Exit(2)
+	}
+	ctx := context.Background()
+
+	fromStore, toStore, err := parseLocations(*from, *to, storeCfg)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	he.Logger.Info("Migration started", "from", fromStore.kind, "to", toStore.kind)
+
+	source, err := openSource(fromStore, storeCfg, 0, ctx)
+	if err != nil {
+		fmt.Println("Error opening source:", err)
+		os.Exit(1)
+	}
+	defer source.Close()
+	dimensions, err := vectorstore.Dimensions(ctx, source)
+	if err != nil {
+		fmt.Println("Error reading source:", err)
+		os.Exit(1)
+	}
+	target, err := createTarget(toStore, storeCfg, dimensions, *replace, ctx)
+	if err != nil {
+		fmt.Println("Error creating target:", err)
+		os.Exit(1)
+	}
+	copied, err := vectorstore.Copy(ctx, source, target, *batch)
+	if err != nil {
+		target.Close()
+		fmt.Println("Error copying documents:", err)
+		os.Exit(1)
+	}
+	if postgres, ok := target.(*vectorstore.Postgres); ok {
+		if err := postgres.CreateIndex(ctx); err != nil {
+			fmt.Println("Error creating vector index:", err)
+		}
+	}
+	// chromem and JSONL are written by Close
+	if err := target.Close(); err != nil {
+		fmt.Println("Error storing target:", err)
+		os.Exit(1)
+	}
+
+	migrated, err := openSource(toStore, storeCfg, dimensions, ctx)
+	if err != nil {
+		fmt.Println("Error opening target:", err)
+		os.Exit(1)
+	}
+	defer migrated.Close()
+	if err := vectorstore.Verify(ctx, source, migrated); err != nil {
+		fmt.Println("Verification failed:", err)
+		os.Exit(1)
+	}
+	checksum, err := vectorstore.Checksums(ctx, migrated)
+	if err != nil {
+		fmt.Println("Error reading target:", err)
+		os.Exit(1)
+	}
+	// the lambda checks the embedding model of the database
+	if err := copyManifest(source, fromStore, migrated, toStore, ctx); err != nil {
+		fmt.Println("Error copying manifest:", err)
+	}
+	he.Logger.Info("Migration finished",
+		"documents", copied,
+		"dimensions", dimensions,
+		"checksum", checksum.Sum)
+}
+
+// storeLocation is the source or the target of the migration
+type storeLocation struct {
+	kind     string
+	location string
+	// table of a pgvector store
+	table string
+}
+
+// parseLocations parses -from and -to. The source cannot be the target,
+// -replace would remove it before it is read.
+func parseLocations(from, to string, cfg vectorstore.Config) (storeLocation, storeLocation, error) {
+	fromStore, err := parseLocation(from, cfg)
+	if err != nil {
+		return storeLocation{}, storeLocation{}, fmt.Errorf("error in -from: %w", err)
+	}
+	toStore, err := parseLocation(to, cfg)
+	if err != nil {
+		return storeLocation{}, storeLocation{}, fmt.Errorf("error in -to: %w", err)
+	}
+	if sameStore(fromStore, toStore) {
+		return storeLocation{}, storeLocation{}, errors.New("-from and -to are the same store")
+	}
+	return fromStore, toStore, nil
+}
+
+// parseLocation splits kind:location, e.g. sqlite:db-data/db.sqlite. A
+// pgvector location ends with its table, e.g. pgvector:postgres://...#docs,
+// the default is the table of the configuration.
+func parseLocation(spec string, cfg vectorstore.Config) (storeLocation, error) {
+	kind, location, _ := strings.Cut(spec, ":")
+	store := storeLocation{kind: kind, location: location}
+	switch kind {
+	case vectorstore.KindPgvector:
+		store.table = cfg.Postgres.Table
+		if separator := strings.LastIndex(location, "#"); separator >= 0 {
+			store.location, store.table = location[:separator], location[separator+1:]
+			if store.table == "" {
+				return storeLocation{}, fmt.Errorf("%v has no table after #", spec)
+			}
+		}
+		if store.location == "" {
+			store.location = cfg.DatabaseURL
+		}
+	case vectorstore.KindChromem, vectorstore.KindSQLite, kindJSONL:
+	default:
+		return storeLocation{}, fmt.Errorf("unknown store %q, expected chromem, sqlite, pgvector or jsonl", kind)
+	}
+	if store.location == "" {
+		return storeLocation{}, fmt.Errorf("%v has no location", kind)
+	}
+	return store, nil
+}
+
+// postgresOptions are the options of the configuration with the table of
+// the location
+func (s storeLocation) postgresOptions(cfg vectorstore.Config) vectorstore.PostgresOptions {
+	options := cfg.Postgres
+	options.Table = s.table
+	return options
+}
+
+// noEmbedding is the embedding function of migrated chromem databases,
+// the documents keep their embeddings and are never queried
+func noEmbedding(ctx context.Context, text string) ([]float32, error) {
+	return nil, errors.New("migrated documents are not embedded again")
+}
+
+// openSource opens the store read only, a chromem database is read with
+// the dimensions or with the dimensions of its manifest if they are 0
+func openSource(store storeLocation, cfg vectorstore.Config, dimensions int, ctx context.Context) (vectorstore.Reader, error) {
+	location := store.location
+	switch store.kind {
+	case vectorstore.KindChromem:
+		file := localstore.FileOptionsFromEnv(localstore.DefaultFileOptions())
+		file.Path = location
+		key, err := file.ReadKey(ctx)
+		if err != nil {
+			return nil, err
+		}
+		db := chromem.NewDB()
+		if err := db.Import(location, key); err != nil {
+			return nil, err
+		}
+		if db.GetCollection(vectorstore.CollectionName, noEmbedding) == nil {
+			return nil, fmt.Errorf("database %v has no %v collection", location, vectorstore.CollectionName)
+		}
+		if dimensions == 0 {
+			// chromem lists the documents with a query of their dimensions
+			manifest, err := localstore.ReadManifest(location)
+			if err != nil {
+				return nil, fmt.Errorf("reading the dimensions of %v: %w", location, err)
+			}
+			if manifest.Dimensions == 0 {
+				return nil, fmt.Errorf("manifest of %v has no dimensions", location)
+			}
+			dimensions = manifest.Dimensions
+		}
+		chromemStore, err := vectorstore.NewChromem(db, nil)
+		if err != nil {
+			return nil, err
+		}
+		chromemStore.SetDimensions(dimensions)
+		return chromemStore, nil
+	case vectorstore.KindSQLite:
+		if _, err := os.Stat(location); err != nil {
+			return nil, err
+		}
+		options := cfg.SQLite
+		options.ReadOnly = true
+		return vectorstore.OpenSQLite(location, options, ctx)
+	case vectorstore.KindPgvector:
+		return vectorstore.ConnectPostgres(location, store.postgresOptions(cfg), ctx)
+	case kindJSONL:
+		return vectorstore.OpenJSONL(location)
+	}
+	return nil, fmt.Errorf("unknown store %q", store.kind)
+}
+
+// createTarget creates an empty target, an existing target is only
+// replaced with replace
+func createTarget(target storeLocation, cfg vectorstore.Config, dimensions int, replace bool, ctx context.Context) (vectorstore.Writer, error) {
+	kind, location := target.kind, target.location
+	if kind == vectorstore.KindPgvector {
+		options := target.postgresOptions(cfg)
+		options.Dimensions = dimensions
+		store, err := vectorstore.ConnectPostgres(location, options, ctx)
+		if err != nil {
+			return nil, err
+		}
+		err = store.Migrate(ctx)
+		if replace && errors.Is(err, vectorstore.ErrDimensionMismatch) {
+			err = store.Drop(ctx)
+			if err == nil {
+				err = store.Migrate(ctx)
+			}
+		}
+		if err == nil && replace {
+			err = store.Truncate(ctx)
+		}
+		if err == nil {
+			var count int
+			count, err = store.Count(ctx)
+			if err == nil && count > 0 {
+				err = fmt.Errorf("table %v has %d documents, use -replace", target.table, count)
+			}
+		}
+		if err != nil {
+			store.Close()
+			return nil, err
+		}
+		return store, nil
+	}
+
+	if _, err := os.Stat(location); err == nil && !replace {
+		return nil, fmt.Errorf("%v exists, use -replace", location)
+	}
+	if err := os.Remove(location); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(location), 0o755); err != nil {
+		return nil, err
+	}
+	switch kind {
+	case vectorstore.KindChromem:
+		db := chromem.NewDB()
+		if _, err := db.CreateCollection(vectorstore.CollectionName, nil, noEmbedding); err != nil {
+			return nil, err
+		}
+		// DB_COMPRESS and the DB_ENCRYPTION_ and DB_KMS_ variables apply
+		file := localstore.FileOptionsFromEnv(localstore.DefaultFileOptions())
+		file.Path = location
+		return vectorstore.NewChromem(db, localstore.StoreFunc(file, ctx))
+	case vectorstore.KindSQLite:
+		return vectorstore.OpenSQLite(location, cfg.SQLite, ctx)
+	case kindJSONL:
+		return vectorstore.CreateJSONL(location)
+	}
+	return nil, fmt.Errorf("unknown store %q", kind)
+}
+
+// sameStore reports whether both locations are the same database file
+// or the same table of the same pgvector database
+func sameStore(from, to storeLocation) bool {
+	fromPostgres, toPostgres := from.kind == vectorstore.KindPgvector, to.kind == vectorstore.KindPgvector
+	if fromPostgres || toPostgres {
+		return fromPostgres && toPostgres && from.location == to.location && from.table == to.table
+	}
+	fromInfo, fromErr := os.Stat(from.location)
+	toInfo, toErr := os.Stat(to.location)
+	if fromErr == nil && toErr == nil {
+		return os.SameFile(fromInfo, toInfo)
+	}
+	fromPath, fromErr := filepath.Abs(from.location)
+	toPath, toErr := filepath.Abs(to.location)
+	return fromErr == nil && toErr == nil && fromPath == toPath
+}
+
+// copyManifest copies the manifest of the source to the migrated
+// database, pgvector keeps it in the database
+func copyManifest(source vectorstore.Reader, from storeLocation, migrated vectorstore.Reader, to storeLocation, ctx context.Context) error {
+	var manifest *localstore.Manifest
+	var err error
+	if db, ok := source.(localstore.ManifestDB); ok {
+		manifest, err = localstore.ReadDBManifest(db, ctx)
+	} else {
+		manifest, err = localstore.ReadManifest(from.location)
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// the next import into the target continues it
+	manifest.Store = to.kind
+	if db, ok := migrated.(localstore.ManifestDB); ok {
+		return localstore.WriteDBManifest(db, manifest, ctx)
+	}
+	return localstore.WriteManifest(to.location, manifest)
+}
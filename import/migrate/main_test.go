@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"hugoembedding/vectorstore"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParseLocations(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "db.sqlite")
+	assert.NilError(t, os.WriteFile(path, []byte("documents"), 0o644))
+	link := filepath.Join(dir, "link.sqlite")
+	assert.NilError(t, os.Link(path, link))
+	cfg := vectorstore.DefaultConfig()
+	cfg.DatabaseURL = "postgres://localhost/rag"
+
+	tests := []struct {
+		name     string
+		from, to string
+		// want are the tables of the pgvector stores
+		want [2]string
+		err  string
+	}{
+		{"same file", "sqlite:" + path, "chromem:" + link, [2]string{}, "-from and -to are the same store"},
+		{"other file", "sqlite:" + path, "jsonl:" + filepath.Join(dir, "export.jsonl"), [2]string{}, ""},
+		{"same table", "pgvector:", "pgvector:postgres://localhost/rag#" + cfg.Postgres.Table, [2]string{}, "-from and -to are the same store"},
+		{"other table", "pgvector:", "pgvector:postgres://localhost/rag#copy", [2]string{cfg.Postgres.Table, "copy"}, ""},
+		{"table of DATABASE_URL", "pgvector:#old", "pgvector:#new", [2]string{"old", "new"}, ""},
+		{"other database", "pgvector:postgres://localhost/rag", "pgvector:postgres://other/rag", [2]string{cfg.Postgres.Table, cfg.Postgres.Table}, ""},
+		{"empty table", "pgvector:postgres://localhost/rag#", "sqlite:" + path, [2]string{}, "error in -from: pgvector:postgres://localhost/rag# has no table after #"},
+		{"unknown store", "sqlite:" + path, "mysql:rag", [2]string{}, `error in -to: unknown store "mysql", expected chromem, sqlite, pgvector or jsonl`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			from, to, err := parseLocations(test.from, test.to, cfg)
+			if test.err != "" {
+				assert.Error(t, err, test.err)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, [2]string{from.table, to.table}, test.want)
+		})
+	}
+}
+
+func TestCreateTargetReplace(t *testing.T) {
+	ctx := context.Background()
+	cfg := vectorstore.DefaultConfig()
+	for _, kind := range []string{vectorstore.KindChromem, vectorstore.KindSQLite, kindJSONL} {
+		t.Run(kind, func(t *testing.T) {
+			target := storeLocation{kind: kind, location: filepath.Join(t.TempDir(), "target")}
+			assert.NilError(t, os.WriteFile(target.location, []byte("existing"), 0o644))
+
+			// an existing target is kept without -replace
+			_, err := createTarget(target, cfg, 64, false, ctx)
+			assert.Error(t, err, target.location+" exists, use -replace")
+			data, err := os.ReadFile(target.location)
+			assert.NilError(t, err)
+			assert.Equal(t, string(data), "existing")
+
+			store, err := createTarget(target, cfg, 64, true, ctx)
+			assert.NilError(t, err)
+			assert.NilError(t, store.Close())
+			data, err = os.ReadFile(target.location)
+			assert.NilError(t, err)
+			assert.Assert(t, string(data) != "existing")
+		})
+	}
+}
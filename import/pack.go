@@ -0,0 +1,198 @@
+package hugoembedding
+
+import (
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PackOptions configures PackChunks
+type PackOptions struct {
+	// TargetTokens is the estimated size of a packed chunk
+	TargetTokens int
+	// OverlapTokens at the end of a packed chunk are repeated at the
+	// beginning of the next chunk of the same section
+	OverlapTokens int
+}
+
+// DefaultPackOptions fit well for the titan embedding models
+var DefaultPackOptions = PackOptions{
+	TargetTokens:  200,
+	OverlapTokens: 20,
+}
+
+// EstimateTokens estimates the number of tokens of the embedding model.
+// Subword tokenizers need about four characters per token for prose,
+// code and other languages have more tokens per word.
+func EstimateTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+	byChars := int(math.Ceil(float64(utf8.RuneCountInString(text)) / 4))
+	words := 0
+	symbols := 0
+	inWord := false
+	for _, r := range text {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if !inWord {
+				words++
+			}
+			inWord = true
+		case unicode.IsSpace(r):
+			inWord = false
+		default:
+			symbols++
+			inWord = false
+		}
+	}
+	byWords := int(math.Ceil(float64(words)*1.3)) + symbols/2
+	if byWords > byChars {
+		return byWords
+	}
+	return byChars
+}
+
+// packGroup is the kind of chunks which may be packed together
+func packGroup(kind ChunkKind) ChunkKind {
+	if kind == ChunkList || kind == "" {
+		return ChunkText
+	}
+	return kind
+}
+
+// PackChunks packs consecutive chunks of the same section up to the
+// target size in tokens. Chunks are never split, so a code block or a
+// list stays complete. Code and image chunks are not packed, prose is
+// not mixed with tables. The metadata of the packed chunks is kept.
+func PackChunks(chunks *[]Chunk, options PackOptions) (*[]Chunk, error) {
+	resultChunks := []Chunk{}
+	var current *Chunk
+	currentTokens := 0
+	overlap := ""
+
+	flush := func() {
+		if current == nil {
+			return
+		}
+		resultChunks = append(resultChunks, *current)
+		current = nil
+		currentTokens = 0
+	}
+
+	for _, chunk := range *chunks {
+		tokens := EstimateTokens(*chunk.Chunk)
+		group := packGroup(chunk.Kind)
+		packable := group == ChunkText || group == ChunkTable
+
+		if current != nil {
+			sameSection := current.HeadingPath == chunk.HeadingPath
+			sameGroup := packGroup(current.Kind) == group
+			if !packable || !sameSection || !sameGroup ||
+				currentTokens+tokens > options.TargetTokens {
+				// the overlap only continues prose within a section
+				overlap = ""
+				if sameSection && sameGroup && group == ChunkText {
+					overlap = lastTokens(*current.Chunk, options.OverlapTokens)
+				}
+				flush()
+			}
+		}
+
+		if current == nil {
+			current = startPack(chunk, overlap)
+			overlap = ""
+			currentTokens = EstimateTokens(*current.Chunk)
+			if !packable {
+				flush()
+			}
+			continue
+		}
+
+		appendPack(current, chunk)
+		currentTokens += tokens
+	}
+	flush()
+	return &resultChunks, nil
+}
+
+// startPack copies the chunk as start of a packed chunk, the overlap is
+// put in front of the text
+func startPack(chunk Chunk, overlap string) *Chunk {
+	text := *chunk.Chunk
+	if overlap != "" {
+		text = overlap + " " + text
+	}
+	aChunk := chunk
+	aChunk.Chunk = &text
+	aChunk.Links = append([]string(nil), chunk.Links...)
+	return &aChunk
+}
+
+// appendPack adds the text and the metadata of the chunk to the packed chunk
+func appendPack(current *Chunk, chunk Chunk) {
+	text := joinBlocks(*current.Chunk, *chunk.Chunk)
+	current.Chunk = &text
+	current.Context = joinContext(current.Context, chunk.Context)
+	if current.Reference == nil {
+		current.Reference = chunk.Reference
+	}
+	current.Links = appendUnique(current.Links, chunk.Links...)
+	if current.Kind != chunk.Kind {
+		current.Kind = packGroup(current.Kind)
+	}
+}
+
+// lastTokens returns the last words of the text which make up the
+// estimated number of tokens
+func lastTokens(text string, tokens int) string {
+	if tokens <= 0 {
+		return ""
+	}
+	words := strings.Fields(text)
+	start := len(words)
+	for start > 0 && EstimateTokens(strings.Join(words[start-1:], " ")) <= tokens {
+		start--
+	}
+	if start == len(words) {
+		return ""
+	}
+	return strings.Join(words[start:], " ")
+}
+
+func joinContext(a *string, b *string) *string {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	}
+	joined := joinBlocks(*a, *b)
+	return &joined
+}
+
+// joinBlocks puts the text of the next markdown element on a new line,
+// lists and tables do not end with a line break
+func joinBlocks(a, b string) string {
+	if a == "" || strings.HasSuffix(a, "\n") {
+		return a + b
+	}
+	return a + "\n" + b
+}
+
+func appendUnique(list []string, values ...string) []string {
+	for _, value := range values {
+		found := false
+		for _, existing := range list {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, value)
+		}
+	}
+	return list
+}
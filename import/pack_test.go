@@ -0,0 +1,79 @@
+package hugoembedding_test
+
+import (
+	"hugoembedding"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"gotest.tools/v3/assert"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	assert.Equal(t, hugoembedding.EstimateTokens(""), 0)
+	assert.Equal(t, hugoembedding.EstimateTokens("Hello world"), 3)
+	// symbols make code more expensive than prose
+	assert.Assert(t, hugoembedding.EstimateTokens("a.b(c){d}") > hugoembedding.EstimateTokens("a b c d"))
+}
+
+func TestPackSeparator(t *testing.T) {
+	tests := []struct {
+		name  string
+		texts []string
+		want  string
+	}{
+		{"list before paragraph", []string{" - one - two", "Next para.\n"}, " - one - two\nNext para.\n"},
+		{"paragraphs", []string{"First.\n", "Second.\n"}, "First.\nSecond.\n"},
+		{"three blocks", []string{"a", "b", "c"}, "a\nb\nc"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var input []hugoembedding.Chunk
+			for _, text := range test.texts {
+				input = append(input, hugoembedding.Chunk{Chunk: aws.String(text), Context: aws.String(text), Kind: hugoembedding.ChunkText})
+			}
+			result, err := hugoembedding.PackChunks(&input, hugoembedding.DefaultPackOptions)
+			assert.NilError(t, err)
+			assert.Equal(t, len(*result), 1)
+			assert.Equal(t, *(*result)[0].Chunk, test.want)
+			assert.Equal(t, *(*result)[0].Context, test.want)
+		})
+	}
+}
+
+func TestPackChunks(t *testing.T) {
+	words := func(n int) string {
+		return strings.Repeat("word ", n)
+	}
+	input := []hugoembedding.Chunk{
+		{Chunk: aws.String(words(10)), HeadingPath: "Setup", Kind: hugoembedding.ChunkText, Links: []string{"/a/"}},
+		{Chunk: aws.String(words(10)), HeadingPath: "Setup", Kind: hugoembedding.ChunkList, Links: []string{"/b/", "/a/"}},
+		{Chunk: aws.String("go run main.go\n"), HeadingPath: "Setup", Kind: hugoembedding.ChunkCode, Language: "bash"},
+		{Chunk: aws.String(words(10)), HeadingPath: "Setup", Kind: hugoembedding.ChunkText},
+		{Chunk: aws.String(words(40)), HeadingPath: "Setup", Kind: hugoembedding.ChunkList},
+		{Chunk: aws.String(words(10)), HeadingPath: "Usage", Kind: hugoembedding.ChunkText},
+	}
+	result, err := hugoembedding.PackChunks(&input, hugoembedding.PackOptions{TargetTokens: 40, OverlapTokens: 3})
+	assert.NilError(t, err)
+	chunks := *result
+	assert.Equal(t, len(chunks), 5)
+
+	// text and list are packed with their links
+	assert.Equal(t, *chunks[0].Chunk, words(10)+"\n"+words(10))
+	assert.Equal(t, chunks[0].Kind, hugoembedding.ChunkText)
+	assert.DeepEqual(t, chunks[0].Links, []string{"/a/", "/b/"})
+
+	// code is never packed with prose
+	assert.Equal(t, chunks[1].Kind, hugoembedding.ChunkCode)
+	assert.Equal(t, chunks[1].Language, "bash")
+	assert.Equal(t, *chunks[1].Chunk, "go run main.go\n")
+
+	// the list is larger than the target but not split
+	assert.Equal(t, *chunks[2].Chunk, words(10))
+	assert.Equal(t, *chunks[3].Chunk, "word word "+words(40))
+	assert.Equal(t, chunks[3].Kind, hugoembedding.ChunkList)
+
+	// no overlap into the next section
+	assert.Equal(t, *chunks[4].Chunk, words(10))
+	assert.Equal(t, chunks[4].HeadingPath, "Usage")
+}
@@ -2,21 +2,58 @@ package hugoembedding
 
 import (
 	"bytes"
+	"strings"
 
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	east "github.com/yuin/goldmark/extension/ast"
 	"github.com/yuin/goldmark/text"
 )
 
 // Parse is a function to parse markdown for chunks to convert
 // to embeddings
 // it takes a byte slice and returns a slice of Chunk pointers and an error.
-func Parse(source []byte) (*[]Chunk, error) {
-	md := goldmark.New(goldmark.WithExtensions())
+// The front matter is not part of the chunks, see ParseMetadata.
+// Hugo shortcodes are replaced according to the Shortcodes rules of the
+// configuration. Each chunk carries the path of the headings it is placed
+// under.
+func Parse(content []byte, cfg *Config) (*[]Chunk, error) {
+	_, _, body := SplitFrontMatter(content)
+	source := resolveRefShortcodes(body)
+	if cfg.Shortcodes != nil {
+		source = cfg.Shortcodes.Process(source)
+	}
+	md := goldmark.New(goldmark.WithExtensions(extension.GFM))
 	reader := text.NewReader(source)
 	doc := md.Parser().Parse(reader)
 
 	var chunks []Chunk = make([]Chunk, 0)
+	// headings[i] is the text of the current heading with level i+1
+	var headings []string
+
+	addChunk := func(kind ChunkKind, text string, links []string) *Chunk {
+		aChunk := Chunk{
+			Chunk:       &text,
+			Context:     &text,
+			Reference:   nil,
+			HeadingPath: headingPath(headings),
+			Links:       links,
+			Kind:        kind,
+		}
+		chunks = append(chunks, aChunk)
+		Logger.Debug("Cunks", "kind", kind, "path", aChunk.HeadingPath, "chunk", *aChunk.Chunk)
+		return &chunks[len(chunks)-1]
+	}
+	// images are chunks of their own with alt text and caption
+	addImages := func(n ast.Node) {
+		for _, image := range extractImages(n, source) {
+			if image.Text() == "" {
+				continue
+			}
+			addChunk(ChunkImage, image.Text()+"\n", nil).Image = image.Destination
+		}
+	}
 
 	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
 		if entering {
@@ -25,76 +62,42 @@ func Parse(source []byte) (*[]Chunk, error) {
 			switch kind {
 			case ast.KindHeading:
 				heading := n.(*ast.Heading)
-				// we look for h1 or h2 headings
-				if heading.Level == 1 || heading.Level == 2 {
-					return ast.WalkContinue, nil
-				}
+				headings = enterHeading(headings, heading.Level, string(heading.Text(source)))
+				return ast.WalkSkipChildren, nil
 			case ast.KindFencedCodeBlock:
 				// n.Dump(source, 2)
-				codeSpanText := extractFencedCodeBlocks(n, source)
-				aChunk := Chunk{
-					Chunk:     &codeSpanText,
-					Context:   &codeSpanText,
-					Reference: nil,
+				code := n.(*ast.FencedCodeBlock)
+				language := ""
+				if code.Info != nil {
+					language = codeLanguage(string(code.Info.Segment.Value(source)))
 				}
-				chunks = append(chunks, aChunk)
-				Logger.Debug("Cunks from markdown", "chunk", *aChunk.Chunk)
-
-				return ast.WalkContinue, nil
+				description := extractCodeDescription(n, source)
+				for _, part := range SplitCode(extractFencedCodeBlocks(n, source), language, CodeChunkSize) {
+					aChunk := addChunk(ChunkCode, part, nil)
+					aChunk.Language = language
+					aChunk.Description = description
+				}
+				return ast.WalkSkipChildren, nil
 			case ast.KindParagraph:
-				prev := n.PreviousSibling()
-				if prev != nil {
-					if prev.Kind() == ast.KindHeading {
-						heading := prev.(*ast.Heading)
-						if heading.Level == 1 || heading.Level == 2 {
-							headingText := heading.Text(source)
-							// Get text from paragraph
-							// paragraphText := n.(*ast.Paragraph)
-
-							paragraphText := string(headingText) +
-								"\n" +
-								extractTextFromParagraph(n.(*ast.Paragraph), source) +
-								"\n"
-							aChunk := Chunk{
-								Chunk:     &paragraphText,
-								Context:   &paragraphText,
-								Reference: nil,
-							}
-							chunks = append(chunks, aChunk)
-							Logger.Debug("Cunks", "chunk", *aChunk.Chunk)
-						}
-					} else {
-						paragraphText := "\n" +
-							extractTextFromParagraph(n.(*ast.Paragraph), source) +
-							"\n"
-						aChunk := Chunk{
-							Chunk:     &paragraphText,
-							Context:   &paragraphText,
-							Reference: nil,
-						}
-						chunks = append(chunks, aChunk)
-						Logger.Debug("Cunks", "chunk", *aChunk.Chunk)
-					}
-
+				paragraphText, links := extractTextFromParagraph(n.(*ast.Paragraph), source)
+				if strings.TrimSpace(paragraphText) != "" {
+					addChunk(ChunkText, paragraphText+"\n", links)
 				}
-			case ast.KindText:
-				text := string(n.(*ast.Text).Text(source))
-				aChunk := Chunk{
-					Chunk:     &text,
-					Context:   &text,
-					Reference: nil,
+				addImages(n)
+				return ast.WalkSkipChildren, nil
+			case east.KindTable:
+				// one chunk per row, so that each row keeps its headers
+				rows, rowLinks := extractTableRows(n.(*east.Table), source)
+				for i, row := range rows {
+					addChunk(ChunkTable, row, rowLinks[i])
 				}
-				chunks = append(chunks, aChunk)
-				Logger.Debug("Cunks", "chunk", *aChunk.Chunk)
-
+				addImages(n)
+				return ast.WalkSkipChildren, nil
 			case ast.KindList:
-				text := extractTextFromList(n.(*ast.List), source)
-				aChunk := Chunk{
-					Chunk:     &text,
-					Context:   &text,
-					Reference: nil,
-				}
-				chunks = append(chunks, aChunk)
+				listText, links := extractTextFromList(n.(*ast.List), source)
+				addChunk(ChunkList, listText, links)
+				addImages(n)
+				return ast.WalkSkipChildren, nil
 			}
 		}
 		return ast.WalkContinue, nil
@@ -103,14 +106,41 @@ func Parse(source []byte) (*[]Chunk, error) {
 	return &chunks, nil
 }
 
-func extractTextFromParagraph(paragraph *ast.Paragraph, source []byte) string {
+// enterHeading sets the heading of the level and forgets all deeper headings
+func enterHeading(headings []string, level int, text string) []string {
+	for len(headings) < level {
+		headings = append(headings, "")
+	}
+	headings = headings[:level]
+	headings[level-1] = strings.TrimSpace(strings.TrimRight(strings.TrimSpace(text), ":"))
+	return headings
+}
+
+// headingPath joins the headings, skipped levels are left out
+func headingPath(headings []string) string {
+	parts := make([]string, 0, len(headings))
+	for _, heading := range headings {
+		if heading != "" {
+			parts = append(parts, heading)
+		}
+	}
+	return strings.Join(parts, HeadingSeparator)
+}
+
+// extractTextFromParagraph returns the text and the link targets of the paragraph
+func extractTextFromParagraph(paragraph *ast.Paragraph, source []byte) (string, []string) {
 	var buffer bytes.Buffer
+	var links []string
 
 	// Iterate through the children of the paragraph node
 	for child := paragraph.FirstChild(); child != nil; child = child.NextSibling() {
 		switch child := child.(type) {
 		case *ast.Text:
 			buffer.Write(child.Text(source))
+			// a line break within the paragraph separates words
+			if child.SoftLineBreak() {
+				buffer.WriteByte(' ')
+			}
 		case *ast.String:
 			buffer.Write(child.Value)
 		case *ast.CodeSpan:
@@ -118,28 +148,42 @@ func extractTextFromParagraph(paragraph *ast.Paragraph, source []byte) string {
 			codeSpanText := extractCodeSpanText(child, source)
 			buffer.WriteString(codeSpanText)
 		case *ast.Emphasis:
-			// If there's emphasis, you have to iterate through the children of this node as well
-			for subChild := child.FirstChild(); subChild != nil; subChild = subChild.NextSibling() {
-				switch subChild := subChild.(type) {
-				case *ast.Text:
-					buffer.Write(subChild.Text(source))
-				case *ast.String:
-					buffer.Write(subChild.Value)
-				}
-			}
+			// emphasis may contain links and code spans
+			text, targets := extractInlineText(child, source)
+			buffer.WriteString(text)
+			links = append(links, targets...)
 		case *ast.Link:
-			// If it's a link, we extract the link text
-			// ###TODO
-			// buffer.WriteString(extractTextFromParagraph(child, source))
-		}
-	}
-	// Add a space if it wasn't the last node and buffer isn't empty to simulate spaces that were implicitly between words/nodes
-	for child := paragraph.FirstChild(); child != nil; child = child.NextSibling() {
-		if buffer.Len() > 0 && child.NextSibling() != nil {
-			buffer.WriteByte(' ')
+			// If it's a link, we keep the link text and remember the target
+			buffer.WriteString(extractLinkText(child, source))
+			links = append(links, string(child.Destination))
+		case *ast.AutoLink:
+			url := string(child.URL(source))
+			buffer.WriteString(url)
+			links = append(links, url)
+		case *east.Strikethrough:
+			// struck text is outdated, it should not be found
 		}
 	}
 
+	return buffer.String(), links
+}
+
+// extractLinkText returns the label of a link, which may contain emphasis or code
+func extractLinkText(link *ast.Link, source []byte) string {
+	var buffer bytes.Buffer
+	_ = ast.Walk(link, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering {
+			switch n := n.(type) {
+			case *ast.Text:
+				buffer.Write(n.Text(source))
+			case *ast.String:
+				buffer.Write(n.Value)
+			case *ast.Image:
+				return ast.WalkSkipChildren, nil
+			}
+		}
+		return ast.WalkContinue, nil
+	})
 	return buffer.String()
 }
 
@@ -175,8 +219,21 @@ func extractFencedCodeBlocks(node ast.Node, source []byte) string {
 	return codeBlockContent
 }
 
-func extractTextFromList(node ast.Node, source []byte) string {
+// extractCodeDescription returns the text of the paragraph directly
+// before the code block, which usually tells what the code does
+func extractCodeDescription(node ast.Node, source []byte) string {
+	prev := node.PreviousSibling()
+	if prev == nil || prev.Kind() != ast.KindParagraph {
+		return ""
+	}
+	description, _ := extractTextFromParagraph(prev.(*ast.Paragraph), source)
+	return strings.TrimSpace(description)
+}
+
+// extractTextFromList returns the text and the link targets of the list
+func extractTextFromList(node ast.Node, source []byte) (string, []string) {
 	var buf bytes.Buffer
+	var links []string
 	_ = ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
 		if entering {
 			kind := n.Kind()
@@ -184,6 +241,20 @@ func extractTextFromList(node ast.Node, source []byte) string {
 			switch t := n.(type) {
 			case *ast.ListItem:
 				buf.Write([]byte(" - "))
+			case *east.TaskCheckBox:
+				if t.IsChecked {
+					buf.WriteString("[x] ")
+				} else {
+					buf.WriteString("[ ] ")
+				}
+			case *east.Strikethrough:
+				return ast.WalkSkipChildren, nil
+			case *ast.Link:
+				links = append(links, string(t.Destination))
+			case *ast.AutoLink:
+				url := string(t.URL(source))
+				buf.WriteString(url)
+				links = append(links, url)
 			case *ast.Text:
 				buf.Write(t.Segment.Value(source))
 			case *ast.String:
@@ -194,5 +265,5 @@ func extractTextFromList(node ast.Node, source []byte) string {
 		}
 		return ast.WalkContinue, nil
 	})
-	return buf.String()
+	return buf.String(), links
 }
@@ -3,6 +3,7 @@ package hugoembedding_test
 import (
 	"hugoembedding"
 	"os"
+	"strings"
 	"testing"
 
 	"gotest.tools/v3/assert"
@@ -16,8 +17,136 @@ func TestParseNumberParagraphs(t *testing.T) {
 	assert.NilError(t, err)
 
 	t.Logf("Call parse \n")
-	chunks, err := hugoembedding.Parse(content)
+	chunks, err := hugoembedding.Parse(content, hugoembedding.DefaultConfig())
 	assert.NilError(t, err)
 
-	assert.Equal(t, len(*chunks), 10)
+	assert.Equal(t, len(*chunks), 14)
+}
+
+func TestParseHeadingPath(t *testing.T) {
+	content := []byte(`---
+title: "Heading"
+---
+Intro without heading.
+
+# Setup
+
+Setup text.
+
+## Prerequisites
+
+### IAM
+
+You need a role.
+
+## Install
+
+- first
+- second
+
+#### Skipped level
+
+Deep text.
+`)
+	chunks, err := hugoembedding.Parse(content, hugoembedding.DefaultConfig())
+	assert.NilError(t, err)
+
+	expected := []string{
+		"",
+		"Setup",
+		"Setup > Prerequisites > IAM",
+		"Setup > Install",
+		"Setup > Install > Skipped level",
+	}
+	assert.Equal(t, len(*chunks), len(expected))
+	for i, chunk := range *chunks {
+		assert.Equal(t, chunk.HeadingPath, expected[i])
+	}
+	assert.Equal(t, (*chunks)[2].EmbeddingText(), "Setup > Prerequisites > IAM\nYou need a role.\n")
+}
+
+func TestParseLinks(t *testing.T) {
+	content := []byte(`See the [credential providers](https://docs.aws.amazon.com/sdk/) for details,
+the [first part]({{< ref "post/2023/part-1/index.md" >}}) and <https://aws.amazon.com>.
+
+- read [**more**]({{< relref "/post/2023/more.md#setup" >}})
+`)
+	chunks, err := hugoembedding.Parse(content, hugoembedding.DefaultConfig())
+	assert.NilError(t, err)
+	assert.Equal(t, len(*chunks), 2)
+
+	paragraph := (*chunks)[0]
+	assert.Assert(t, strings.Contains(*paragraph.Chunk, "See the credential providers for details,"))
+	assert.Assert(t, strings.Contains(*paragraph.Chunk, "the first part and https://aws.amazon.com."))
+	assert.DeepEqual(t, paragraph.Links, []string{
+		"https://docs.aws.amazon.com/sdk/",
+		"/post/2023/part-1/",
+		"https://aws.amazon.com",
+	})
+
+	list := (*chunks)[1]
+	assert.Assert(t, strings.Contains(*list.Chunk, "read more"))
+	assert.DeepEqual(t, list.Links, []string{"/post/2023/more/#setup"})
+}
+
+func TestParseEmphasis(t *testing.T) {
+	content := []byte("Read *see [docs](https://example.com/docs/)* and **use `foo`** now.\n")
+	chunks, err := hugoembedding.Parse(content, hugoembedding.DefaultConfig())
+	assert.NilError(t, err)
+	assert.Equal(t, len(*chunks), 1)
+	assert.Equal(t, *(*chunks)[0].Chunk, "Read see docs and use foo now.\n")
+	assert.DeepEqual(t, (*chunks)[0].Links, []string{"https://example.com/docs/"})
+}
+
+func TestParseGFM(t *testing.T) {
+	content := []byte(`## Limits
+
+| Service | Timeout | Docs |
+|---------|---------|------|
+| Lambda  | 15 min  | [limits](https://docs.aws.amazon.com/lambda/) |
+| Fargate |         | |
+
+Use ~~Lambda@Edge~~ CloudFront Functions.
+
+- [x] deploy
+- [ ] test
+`)
+	chunks, err := hugoembedding.Parse(content, hugoembedding.DefaultConfig())
+	assert.NilError(t, err)
+	assert.Equal(t, len(*chunks), 4)
+
+	assert.Equal(t, *(*chunks)[0].Chunk, "Service: Lambda; Timeout: 15 min; Docs: limits\n")
+	assert.Equal(t, (*chunks)[0].HeadingPath, "Limits")
+	assert.DeepEqual(t, (*chunks)[0].Links, []string{"https://docs.aws.amazon.com/lambda/"})
+	assert.Equal(t, *(*chunks)[1].Chunk, "Service: Fargate\n")
+	assert.Equal(t, *(*chunks)[2].Chunk, "Use  CloudFront Functions.\n")
+	assert.Equal(t, *(*chunks)[3].Chunk, " - [x] deploy - [ ] test")
+}
+
+func TestParseImages(t *testing.T) {
+	content := []byte(`## Architecture
+
+The design:
+![Lambda with local database](img/architecture.png "Query flow")
+
+{{< figure src="/img/2019/05/trick-overview-cdk.png" title="cdk" >}}
+
+- Preview ![](adjust-image.png)
+`)
+	chunks, err := hugoembedding.Parse(content, hugoembedding.DefaultConfig())
+	assert.NilError(t, err)
+
+	var images []hugoembedding.Chunk
+	for _, chunk := range *chunks {
+		if chunk.Kind == hugoembedding.ChunkImage {
+			images = append(images, chunk)
+		}
+	}
+	assert.Equal(t, len(images), 2)
+	assert.Equal(t, *images[0].Chunk, "Lambda with local database\nQuery flow\n")
+	assert.Equal(t, images[0].Image, "img/architecture.png")
+	assert.Equal(t, images[0].HeadingPath, "Architecture")
+	assert.Equal(t, *images[1].Chunk, "cdk\n")
+	assert.Equal(t, images[1].Image, "/img/2019/05/trick-overview-cdk.png")
+	assert.Equal(t, (*chunks)[0].Kind, hugoembedding.ChunkText)
 }
@@ -1,80 +1,12 @@
 package hugoembedding
 
 import (
-	"context"
 	"fmt"
 	"os"
 	"strings"
 	"time"
-
-	"github.com/jackc/pgx/v5"
-	be "github.com/megaproaktiv/bedrockembedding/titan"
-	"github.com/pgvector/pgvector-go"
-	"gopkg.in/yaml.v2"
 )
 
-type Metadata struct {
-	Title string
-	Autor string `yaml:"author"`
-	Tags  []string
-	Date  string
-}
-
-// Call process and import into embedding
-func ProcessIndex(path string, baseRef string, conn *pgx.Conn, ctx context.Context) error {
-	Logger.Info("Processing Index", "path", path)
-	// Get chunks from file
-	markdownFileContent, err := os.ReadFile(path)
-	chunks, err := Parse(markdownFileContent)
-	if err != nil {
-		Logger.Error("Error parsing markdown file", "error", err)
-		return err
-	}
-
-	// Compress Chunks
-	chunks, err = CompressChunks(chunks, 300)
-	if err != nil {
-		Logger.Error("Error compressing chunks", "error", err)
-		return err
-	}
-	// Get Metadata
-	meta, err := ExtractMetadata(path)
-	title := meta.Title
-	link := baseRef + Path2Link(path, 1, meta.Date)
-	// Put chunks into database
-	for i, chunk := range *chunks {
-		content := chunk.Chunk
-		singleEmbedding, err := be.FetchEmbedding(*content)
-		if err != nil {
-			panic(err)
-		}
-		context := content
-		c := *chunks
-		if i > 0 && i < len(c)-1 {
-			a := *(c[i-1].Chunk)
-			b := *(c[i].Chunk)
-			c := *(c[i+1].Chunk)
-			cs := (a + b + c)
-			context = &cs
-		}
-		// 		_, err = conn.Exec(ctx, "CREATE TABLE pow (id bigserial PRIMARY KEY, content text, context text, link text, title text, embedding vector(1536))")
-
-		sql := "INSERT INTO pow (content, context,title, link, embedding) VALUES ($1, $2, $3, $4, $5)"
-		Logger.Debug("SQL", "sql", sql)
-		_, err = conn.Exec(ctx, sql,
-			content,
-			context,
-			title,
-			link,
-			pgvector.NewVector(singleEmbedding))
-		if err != nil {
-			panic(err)
-		}
-		fmt.Printf("Cluster %v: {%v}\n / [%v]\n", i+1, content, context)
-	}
-	return nil
-}
-
 // Todo
 // Convert
 // /Users/gglawe/Documents/projects/community/2024/pearls/content/post/2024/pyrightconfig-zed/index.md
@@ -87,12 +19,13 @@ func Path2Link(path string, conversionMethod int, metadate string) string {
 	var link string
 	if conversionMethod == 1 {
 		parts = strings.Split(path, "content/")
-		Logger.Debug("Parts", "parts", parts[1])
-		link = parts[1]
+		// outside of a hugo site, e.g. testdata, the path is the link
+		link = parts[len(parts)-1]
+		Logger.Debug("Parts", "parts", link)
 	}
 	if conversionMethod == 2 {
 		parts = strings.Split(path, "post/")
-		innerParts := strings.Split(parts[1], "/")
+		innerParts := strings.Split(parts[len(parts)-1], "/")
 		fileName := innerParts[len(innerParts)-1]
 		// Stip suffix ".md" from filename
 		fileName = strings.Replace(fileName, ".md", "", 1)
@@ -113,22 +46,11 @@ func Path2Link(path string, conversionMethod int, metadate string) string {
 }
 
 func ExtractMetadata(filePath string) (*Metadata, error) {
-
-	file, err := os.Open(filePath)
+	content, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
-
-	meta := &Metadata{}
-
-	decoder := yaml.NewDecoder(file)
-	err = decoder.Decode(meta)
-	if err != nil {
-		return nil, err
-	}
-
-	return meta, nil
+	return ParseMetadata(content)
 }
 
 func TryParseDateMonth(dateStr string) (*string, error) {
@@ -136,6 +58,7 @@ func TryParseDateMonth(dateStr string) (*string, error) {
 	formats := []string{
 		"Wed, 02 Jan 2006 15:04:05 -0700", // dd-Mmm-yyyy
 		"2006-01-02",
+		time.RFC3339,
 	}
 
 	// Try each format until one succeeds or all fail
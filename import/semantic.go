@@ -0,0 +1,134 @@
+package hugoembedding
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// EmbedFunc returns the embedding of a text
+type EmbedFunc func(ctx context.Context, text string) ([]float32, error)
+
+// SemanticOptions configures SemanticChunks
+type SemanticOptions struct {
+	// BreakpointPercentile of the similarities between neighbouring
+	// paragraphs, a new chunk starts below it
+	BreakpointPercentile float64
+	// MaxTokens is the maximum estimated size of a chunk
+	MaxTokens int
+}
+
+// DefaultSemanticOptions starts a new chunk at the quarter of the
+// least similar neighbours
+var DefaultSemanticOptions = SemanticOptions{
+	BreakpointPercentile: 25,
+	MaxTokens:            400,
+}
+
+// SemanticChunks embeds the prose chunks and combines neighbours as long as
+// they are similar. A new chunk starts where the similarity to the previous
+// paragraph drops below the percentile of all neighbour similarities of the
+// document, at a new section and when MaxTokens is reached.
+// Code and image chunks stay on their own, prose is not mixed with tables
+// like in PackChunks.
+func SemanticChunks(ctx context.Context, chunks *[]Chunk, embed EmbedFunc, options SemanticOptions) (*[]Chunk, error) {
+	input := *chunks
+	embeddings := make([][]float32, len(input))
+	for i, chunk := range input {
+		if !semanticUnit(chunk) {
+			continue
+		}
+		embedding, err := embed(ctx, *chunk.Chunk)
+		if err != nil {
+			return nil, fmt.Errorf("embedding chunk %d: %w", i, err)
+		}
+		embeddings[i] = embedding
+	}
+
+	// similarity[i] is the similarity of chunk i to chunk i-1,
+	// NaN if they can not be combined anyway
+	similarity := make([]float64, len(input))
+	var candidates []float64
+	for i := range input {
+		similarity[i] = math.NaN()
+		if i == 0 || embeddings[i] == nil || embeddings[i-1] == nil ||
+			input[i].HeadingPath != input[i-1].HeadingPath ||
+			packGroup(input[i].Kind) != packGroup(input[i-1].Kind) {
+			continue
+		}
+		similarity[i] = cosineSimilarity(embeddings[i-1], embeddings[i])
+		candidates = append(candidates, similarity[i])
+	}
+	threshold := percentile(candidates, options.BreakpointPercentile)
+	Logger.Debug("Semantic breakpoint", "threshold", threshold, "neighbours", len(candidates))
+
+	resultChunks := []Chunk{}
+	var current *Chunk
+	currentTokens := 0
+	for i, chunk := range input {
+		tokens := EstimateTokens(*chunk.Chunk)
+		if current != nil {
+			if math.IsNaN(similarity[i]) || similarity[i] < threshold ||
+				currentTokens+tokens > options.MaxTokens {
+				resultChunks = append(resultChunks, *current)
+				current = nil
+			}
+		}
+		if current == nil {
+			current = startPack(chunk, "")
+			currentTokens = tokens
+			continue
+		}
+		appendPack(current, chunk)
+		currentTokens += tokens
+	}
+	if current != nil {
+		resultChunks = append(resultChunks, *current)
+	}
+	return &resultChunks, nil
+}
+
+// semanticUnit are the chunks which are compared by similarity
+func semanticUnit(chunk Chunk) bool {
+	switch packGroup(chunk.Kind) {
+	case ChunkText, ChunkTable:
+		return true
+	}
+	return false
+}
+
+func cosineSimilarity(a []float32, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// percentile with linear interpolation, -Inf for no values
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return math.Inf(-1)
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower < 0 {
+		return sorted[0]
+	}
+	if upper >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
@@ -0,0 +1,87 @@
+package hugoembedding_test
+
+import (
+	"context"
+	"hugoembedding"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"gotest.tools/v3/assert"
+)
+
+// topicEmbedding embeds a text by the topics it mentions
+func topicEmbedding(ctx context.Context, text string) ([]float32, error) {
+	topics := []string{"lambda", "bedrock", "postgres"}
+	embedding := make([]float32, len(topics))
+	for i, topic := range topics {
+		embedding[i] = float32(strings.Count(text, topic))
+	}
+	return embedding, nil
+}
+
+func TestSemanticChunks(t *testing.T) {
+	input := []hugoembedding.Chunk{
+		{Chunk: aws.String("lambda runs the function. "), HeadingPath: "Intro", Kind: hugoembedding.ChunkText},
+		{Chunk: aws.String("lambda scales. "), HeadingPath: "Intro", Kind: hugoembedding.ChunkText},
+		{Chunk: aws.String("bedrock embeds the text. "), HeadingPath: "Intro", Kind: hugoembedding.ChunkText},
+		{Chunk: aws.String("bedrock has models. "), HeadingPath: "Intro", Kind: hugoembedding.ChunkList},
+		{Chunk: aws.String("fmt.Println(\"bedrock\")\n"), HeadingPath: "Intro", Kind: hugoembedding.ChunkCode},
+		{Chunk: aws.String("postgres stores vectors. "), HeadingPath: "Storage", Kind: hugoembedding.ChunkText},
+	}
+	options := hugoembedding.SemanticOptions{BreakpointPercentile: 25, MaxTokens: 400}
+	result, err := hugoembedding.SemanticChunks(context.TODO(), &input, topicEmbedding, options)
+	assert.NilError(t, err)
+	chunks := *result
+	assert.Equal(t, len(chunks), 4)
+	assert.Equal(t, *chunks[0].Chunk, "lambda runs the function. \nlambda scales. ")
+	assert.Equal(t, *chunks[1].Chunk, "bedrock embeds the text. \nbedrock has models. ")
+	assert.Equal(t, chunks[1].Kind, hugoembedding.ChunkText)
+	assert.Equal(t, chunks[2].Kind, hugoembedding.ChunkCode)
+	assert.Equal(t, chunks[3].HeadingPath, "Storage")
+
+	// the size limit breaks similar paragraphs
+	options.MaxTokens = 5
+	result, err = hugoembedding.SemanticChunks(context.TODO(), &input, topicEmbedding, options)
+	assert.NilError(t, err)
+	assert.Equal(t, len(*result), 6)
+}
+
+func TestSemanticChunksTable(t *testing.T) {
+	input := []hugoembedding.Chunk{
+		{Chunk: aws.String("lambda runs the function. "), HeadingPath: "Intro", Kind: hugoembedding.ChunkText},
+		{Chunk: aws.String("| lambda | 128 MB |\n"), HeadingPath: "Intro", Kind: hugoembedding.ChunkTable},
+		{Chunk: aws.String("| lambda | 256 MB |\n"), HeadingPath: "Intro", Kind: hugoembedding.ChunkTable},
+		{Chunk: aws.String("lambda scales. "), HeadingPath: "Intro", Kind: hugoembedding.ChunkText},
+	}
+	result, err := hugoembedding.SemanticChunks(context.TODO(), &input, topicEmbedding, hugoembedding.DefaultSemanticOptions)
+	assert.NilError(t, err)
+	chunks := *result
+	// the same topic, but prose and tables are not mixed
+	assert.Equal(t, len(chunks), 3)
+	assert.Equal(t, chunks[0].Kind, hugoembedding.ChunkText)
+	assert.Equal(t, *chunks[1].Chunk, "| lambda | 128 MB |\n| lambda | 256 MB |\n")
+	assert.Equal(t, chunks[1].Kind, hugoembedding.ChunkTable)
+	assert.Equal(t, chunks[2].Kind, hugoembedding.ChunkText)
+}
+
+func TestChunkDocument(t *testing.T) {
+	input := []hugoembedding.Chunk{
+		{Chunk: aws.String("lambda runs the function. "), HeadingPath: "Intro", Kind: hugoembedding.ChunkText},
+		{Chunk: aws.String("lambda scales. "), HeadingPath: "Intro", Kind: hugoembedding.ChunkText},
+		{Chunk: aws.String("bedrock embeds the text. "), HeadingPath: "Intro", Kind: hugoembedding.ChunkText},
+	}
+	cfg := hugoembedding.DefaultConfig()
+	result, err := hugoembedding.ChunkDocument(&input, cfg, topicEmbedding, context.TODO())
+	assert.NilError(t, err)
+	assert.Equal(t, len(*result), 1)
+
+	cfg.Chunker = hugoembedding.ChunkerSemantic
+	result, err = hugoembedding.ChunkDocument(&input, cfg, topicEmbedding, context.TODO())
+	assert.NilError(t, err)
+	assert.Equal(t, len(*result), 2)
+
+	cfg.Chunker = "sentences"
+	_, err = hugoembedding.ChunkDocument(&input, cfg, topicEmbedding, context.TODO())
+	assert.ErrorContains(t, err, "unknown chunker")
+}
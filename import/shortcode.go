@@ -0,0 +1,509 @@
+package hugoembedding
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ShortcodeAction is what the import does with a Hugo shortcode
+type ShortcodeAction int
+
+const (
+	// ShortcodeExpand keeps the inner content of a paired shortcode
+	ShortcodeExpand ShortcodeAction = iota
+	// ShortcodeCode turns the inner content into a fenced code block,
+	// the first positional parameter is the language
+	ShortcodeCode
+	// ShortcodeAttribute replaces the shortcode with the first
+	// non empty of its Attributes
+	ShortcodeAttribute
+	// ShortcodeDrop removes the shortcode and its inner content
+	ShortcodeDrop
+	// ShortcodeImage turns a figure into a markdown image, which is
+	// imported as image chunk with alt text and caption
+	ShortcodeImage
+)
+
+// ShortcodeRule configures the handling of one shortcode
+type ShortcodeRule struct {
+	Action     ShortcodeAction
+	Attributes []string
+}
+
+// ShortcodeProcessor replaces shortcodes in markdown before it is parsed
+type ShortcodeProcessor struct {
+	// Rules by shortcode name
+	Rules map[string]ShortcodeRule
+	// Default is used for shortcodes without a rule
+	Default ShortcodeRule
+}
+
+// NewShortcodeProcessor returns a processor with rules for the Hugo
+// built-in shortcodes. Unknown shortcodes are expanded.
+func NewShortcodeProcessor() *ShortcodeProcessor {
+	drop := ShortcodeRule{Action: ShortcodeDrop}
+	return &ShortcodeProcessor{
+		Rules: map[string]ShortcodeRule{
+			"highlight": {Action: ShortcodeCode},
+			"figure":    {Action: ShortcodeImage},
+			"notice":    {Action: ShortcodeExpand},
+			"youtube":   drop,
+			"vimeo":     drop,
+			"gist":      drop,
+			"tweet":     drop,
+			"x":         drop,
+			"instagram": drop,
+		},
+		Default: ShortcodeRule{Action: ShortcodeExpand},
+	}
+}
+
+// shortcodeActions are the names of the actions in the rules of Set
+var shortcodeActions = map[string]ShortcodeAction{
+	"expand":    ShortcodeExpand,
+	"code":      ShortcodeCode,
+	"attribute": ShortcodeAttribute,
+	"drop":      ShortcodeDrop,
+	"image":     ShortcodeImage,
+}
+
+// Set adds the rule of a shortcode, e.g. notice=drop or
+// button=attribute:text,title. The name * sets the Default rule. The
+// processor is a flag.Value, the flag can be repeated.
+func (p *ShortcodeProcessor) Set(spec string) error {
+	name, action, found := strings.Cut(spec, "=")
+	name = strings.TrimSpace(name)
+	if !found || name == "" {
+		return fmt.Errorf("shortcode rule %q is not name=action", spec)
+	}
+	action, attributes, _ := strings.Cut(action, ":")
+	rule := ShortcodeRule{}
+	var ok bool
+	if rule.Action, ok = shortcodeActions[strings.TrimSpace(action)]; !ok {
+		return fmt.Errorf("unknown shortcode action %q, use expand, code, attribute, drop or image", action)
+	}
+	for _, attribute := range strings.Split(attributes, ",") {
+		if attribute = strings.TrimSpace(attribute); attribute != "" {
+			rule.Attributes = append(rule.Attributes, attribute)
+		}
+	}
+	if rule.Action == ShortcodeAttribute && len(rule.Attributes) == 0 {
+		return fmt.Errorf("shortcode rule %q has no attributes", spec)
+	}
+	if name == "*" {
+		p.Default = rule
+		return nil
+	}
+	if p.Rules == nil {
+		p.Rules = map[string]ShortcodeRule{}
+	}
+	p.Rules[name] = rule
+	return nil
+}
+
+// String lists the rules in the format of Set, sorted by name
+func (p *ShortcodeProcessor) String() string {
+	if p == nil {
+		return ""
+	}
+	rules := []string{}
+	for name, rule := range p.Rules {
+		rules = append(rules, name+"="+rule.String())
+	}
+	sort.Strings(rules)
+	return strings.Join(append(rules, "*="+p.Default.String()), " ")
+}
+
+// String is the rule in the format of ShortcodeProcessor.Set
+func (r ShortcodeRule) String() string {
+	for name, action := range shortcodeActions {
+		if action != r.Action {
+			continue
+		}
+		if len(r.Attributes) > 0 {
+			return name + ":" + strings.Join(r.Attributes, ",")
+		}
+		return name
+	}
+	return strconv.Itoa(int(r.Action))
+}
+
+// shortcode is one {{< name params >}} or {{% /name %}} tag
+type shortcode struct {
+	start, end  int
+	name        string
+	closing     bool
+	selfClosing bool
+	positional  []string
+	named       map[string]string
+}
+
+// Process applies the rules to all shortcodes in the source. Shortcodes
+// in fenced code blocks and code spans are kept, they are examples.
+func (p *ShortcodeProcessor) Process(source []byte) []byte {
+	var out bytes.Buffer
+	code := codeRanges(source)
+	pos := 0
+	for {
+		tag, ok := nextShortcode(source, pos, code)
+		if !ok {
+			out.Write(source[pos:])
+			return out.Bytes()
+		}
+		out.Write(source[pos:tag.start])
+		pos = tag.end
+		if tag.closing {
+			// closing tag without opening tag
+			continue
+		}
+
+		rule := p.rule(tag.name)
+		var inner []byte
+		paired := false
+		if !tag.selfClosing {
+			if closeTag, ok := findClosing(source, tag, code); ok {
+				inner = source[tag.end:closeTag.start]
+				// only expanded content is prose, the content of a
+				// highlight keeps its shortcodes like a fenced code block
+				if rule.Action == ShortcodeExpand {
+					inner = p.Process(inner)
+				}
+				pos = closeTag.end
+				paired = true
+			}
+		}
+		out.Write(apply(rule, tag, inner, paired))
+	}
+}
+
+func (p *ShortcodeProcessor) rule(name string) ShortcodeRule {
+	if rule, ok := p.Rules[name]; ok {
+		return rule
+	}
+	return p.Default
+}
+
+func apply(rule ShortcodeRule, tag shortcode, inner []byte, paired bool) []byte {
+	switch rule.Action {
+	case ShortcodeExpand:
+		return inner
+	case ShortcodeCode:
+		if !paired {
+			return nil
+		}
+		language := ""
+		if len(tag.positional) > 0 {
+			language = tag.positional[0]
+		}
+		if lang, ok := tag.named["lang"]; ok {
+			language = lang
+		}
+		code := strings.Trim(string(inner), "\n")
+		return []byte("\n```" + language + "\n" + code + "\n```\n")
+	case ShortcodeImage:
+		return figureImage(tag.named)
+	case ShortcodeAttribute:
+		for _, attribute := range rule.Attributes {
+			if value := strings.TrimSpace(tag.named[attribute]); value != "" {
+				return []byte(value)
+			}
+		}
+	}
+	return nil
+}
+
+// findClosing looks for the closing tag of the opening tag, nested
+// shortcodes with the same name are skipped
+func findClosing(source []byte, open shortcode, code []codeRange) (shortcode, bool) {
+	depth := 0
+	pos := open.end
+	for {
+		tag, ok := nextShortcode(source, pos, code)
+		if !ok {
+			return shortcode{}, false
+		}
+		pos = tag.end
+		if tag.name != open.name || tag.selfClosing {
+			continue
+		}
+		if !tag.closing {
+			depth++
+			continue
+		}
+		if depth == 0 {
+			return tag, true
+		}
+		depth--
+	}
+}
+
+// nextShortcode finds the next shortcode tag starting at from outside of
+// the code ranges
+func nextShortcode(source []byte, from int, code []codeRange) (shortcode, bool) {
+	for from < len(source) {
+		offset := bytes.Index(source[from:], []byte("{{"))
+		if offset < 0 || from+offset+2 >= len(source) {
+			return shortcode{}, false
+		}
+		start := from + offset
+		if r, ok := inCode(code, start); ok {
+			from = r.end
+			continue
+		}
+		delimiter := source[start+2]
+		if delimiter != '<' && delimiter != '%' {
+			from = start + 2
+			continue
+		}
+		closer := []byte("%}}")
+		if delimiter == '<' {
+			closer = []byte(">}}")
+		}
+		end := indexOutsideQuotes(source[start+3:], closer)
+		if end < 0 {
+			return shortcode{}, false
+		}
+		content := string(source[start+3 : start+3+end])
+		tag := parseShortcode(content)
+		if tag.name == "" {
+			from = start + 2
+			continue
+		}
+		tag.start = start
+		tag.end = start + 3 + end + len(closer)
+		return tag, true
+	}
+	return shortcode{}, false
+}
+
+// indexOutsideQuotes is bytes.Index which ignores quoted parameters
+func indexOutsideQuotes(source []byte, sep []byte) int {
+	var quote byte
+	for i := 0; i < len(source); i++ {
+		c := source[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++ // escaped character
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '`':
+			quote = c
+		case bytes.HasPrefix(source[i:], sep):
+			return i
+		}
+	}
+	return -1
+}
+
+// parseShortcode parses the content between the delimiters,
+// e.g. ` figure src="a.png" title="A" `
+func parseShortcode(content string) shortcode {
+	tag := shortcode{named: map[string]string{}}
+	content = strings.TrimSpace(content)
+	if strings.HasPrefix(content, "/") {
+		tag.closing = true
+		content = strings.TrimSpace(content[1:])
+	}
+	if strings.HasSuffix(content, "/") {
+		tag.selfClosing = true
+		content = strings.TrimSpace(strings.TrimSuffix(content, "/"))
+	}
+
+	tokens := splitParams(content)
+	if len(tokens) == 0 {
+		return tag
+	}
+	tag.name = tokens[0]
+	for _, token := range tokens[1:] {
+		key, value, found := strings.Cut(token, "=")
+		if found && !strings.HasPrefix(key, "\"") {
+			tag.named[key] = unquote(value)
+		} else {
+			tag.positional = append(tag.positional, unquote(token))
+		}
+	}
+	return tag
+}
+
+// splitParams splits at whitespace outside of quotes
+func splitParams(content string) []string {
+	var tokens []string
+	var current strings.Builder
+	var quote rune
+	escaped := false
+	for _, c := range content {
+		switch {
+		case escaped:
+			escaped = false
+			current.WriteRune(c)
+		case quote == '"' && c == '\\':
+			escaped = true
+			current.WriteRune(c)
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+			current.WriteRune(c)
+		case c == '"' || c == '`':
+			quote = c
+			current.WriteRune(c)
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(c)
+		}
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
+
+func unquote(value string) string {
+	if unquoted, err := strconv.Unquote(value); err == nil && strings.HasPrefix(value, `"`) {
+		return unquoted
+	}
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '`') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// codeRange is a fenced code block or a code span of the source
+type codeRange struct {
+	start, end int
+}
+
+// codeRanges finds the fenced code blocks and the code spans. A fence
+// without closing fence ends with the source, a code span ends at the
+// next backtick run of the same length before a blank line.
+func codeRanges(source []byte) []codeRange {
+	var ranges []codeRange
+	pos := 0
+	for pos < len(source) {
+		lineEnd := bytes.IndexByte(source[pos:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(source)
+		} else {
+			lineEnd += pos + 1
+		}
+		line := source[pos:lineEnd]
+		lineStart := pos == 0 || source[pos-1] == '\n'
+		if fence := codeFence(line); lineStart && fence != "" {
+			end := len(source)
+			for next := lineEnd; next < len(source); {
+				nextEnd := bytes.IndexByte(source[next:], '\n')
+				if nextEnd < 0 {
+					nextEnd = len(source)
+				} else {
+					nextEnd += next + 1
+				}
+				closing := bytes.TrimSpace(source[next:nextEnd])
+				if bytes.HasPrefix(closing, []byte(fence)) && len(bytes.Trim(closing, fence[:1])) == 0 {
+					end = nextEnd
+					break
+				}
+				next = nextEnd
+			}
+			ranges = append(ranges, codeRange{pos, end})
+			pos = end
+			continue
+		}
+		ranges = append(ranges, codeSpans(source, pos, lineEnd)...)
+		if len(ranges) > 0 && ranges[len(ranges)-1].end > lineEnd {
+			// the code span continues on the next lines
+			lineEnd = ranges[len(ranges)-1].end
+		}
+		pos = lineEnd
+	}
+	return ranges
+}
+
+// codeFence returns the opening fence of the line, e.g. ``` or ~~~~
+func codeFence(line []byte) string {
+	trimmed := bytes.TrimLeft(line, " \t")
+	for _, c := range []byte{'`', '~'} {
+		n := 0
+		for n < len(trimmed) && trimmed[n] == c {
+			n++
+		}
+		if n >= 3 {
+			// the info string of a backtick fence has no backticks
+			if c == '`' && bytes.IndexByte(trimmed[n:], '`') >= 0 {
+				return ""
+			}
+			return string(trimmed[:n])
+		}
+	}
+	return ""
+}
+
+// codeSpans finds the code spans which start in the line
+func codeSpans(source []byte, start, lineEnd int) []codeRange {
+	var ranges []codeRange
+	for i := start; i < lineEnd; {
+		if source[i] != '`' {
+			i++
+			continue
+		}
+		n := backtickRun(source, i)
+		end := -1
+		for j := i + n; j < len(source); {
+			if source[j] == '\n' && j+1 < len(source) && isBlankLine(source[j+1:]) {
+				break
+			}
+			if source[j] != '`' {
+				j++
+				continue
+			}
+			m := backtickRun(source, j)
+			if m == n {
+				end = j + m
+				break
+			}
+			j += m
+		}
+		if end < 0 {
+			// an unmatched backtick run is text
+			i += n
+			continue
+		}
+		ranges = append(ranges, codeRange{i, end})
+		i = end
+	}
+	return ranges
+}
+
+func backtickRun(source []byte, i int) int {
+	n := 0
+	for i+n < len(source) && source[i+n] == '`' {
+		n++
+	}
+	return n
+}
+
+func isBlankLine(source []byte) bool {
+	end := bytes.IndexByte(source, '\n')
+	if end < 0 {
+		end = len(source)
+	}
+	return len(bytes.TrimSpace(source[:end])) == 0
+}
+
+// inCode returns the code range which contains the position
+func inCode(ranges []codeRange, pos int) (codeRange, bool) {
+	for _, r := range ranges {
+		if pos >= r.start && pos < r.end {
+			return r, true
+		}
+	}
+	return codeRange{}, false
+}
@@ -0,0 +1,166 @@
+package hugoembedding_test
+
+import (
+	"hugoembedding"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestShortcodeProcess(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "highlight becomes code block",
+			source: "Code:\n{{< highlight go >}}\nfunc main() {}\n{{< /highlight >}}\nDone",
+			want:   "Code:\n\n```go\nfunc main() {}\n```\n\nDone",
+		},
+		{
+			name:   "highlight keeps shortcodes of its code",
+			source: "{{< highlight go >}}\nfmt.Println(\"{{< youtube x >}}\")\n{{< /highlight >}}",
+			want:   "\n```go\nfmt.Println(\"{{< youtube x >}}\")\n```\n",
+		},
+		{
+			name:   "figure becomes image with caption",
+			source: `{{< figure src="/img/overview.png" alt="Overview" caption="The \"big\" picture" >}}`,
+			want:   "\n\n![Overview](</img/overview.png> \"The \\\"big\\\" picture\")\n\n",
+		},
+		{
+			name:   "figure falls back to title",
+			source: `{{< figure src="/img/cdk.png" title="cdk" >}}`,
+			want:   "\n\n![](</img/cdk.png> \"cdk\")\n\n",
+		},
+		{
+			name:   "youtube is dropped",
+			source: "Watch {{< youtube kgkcof1-zY8 >}}this",
+			want:   "Watch this",
+		},
+		{
+			name:   "paired markdown shortcode is expanded",
+			source: "{{% notice note %}}\nUse the **force**.\n{{% /notice %}}",
+			want:   "\nUse the **force**.\n",
+		},
+		{
+			name:   "unknown self closing shortcode is dropped",
+			source: "a {{< toc />}}b",
+			want:   "a b",
+		},
+		{
+			name:   "nested shortcodes",
+			source: "{{% notice %}}x {{% notice %}}y{{% /notice %}}{{% /notice %}}",
+			want:   "x y",
+		},
+		{
+			name:   "go templates are not shortcodes",
+			source: "{{ .Title }}",
+			want:   "{{ .Title }}",
+		},
+		{
+			name:   "fenced code keeps shortcodes",
+			source: "```md\n{{< highlight go >}}\nx\n{{< /highlight >}}\n```\n{{< youtube a >}}",
+			want:   "```md\n{{< highlight go >}}\nx\n{{< /highlight >}}\n```\n",
+		},
+		{
+			name:   "unclosed fence keeps shortcodes",
+			source: "~~~~\n{{< youtube a >}}\n~~~\n",
+			want:   "~~~~\n{{< youtube a >}}\n~~~\n",
+		},
+		{
+			name:   "code span keeps shortcodes",
+			source: "Write `{{< youtube a >}}` or ``{{< x >}}`` {{< youtube b >}}",
+			want:   "Write `{{< youtube a >}}` or ``{{< x >}}`` ",
+		},
+		{
+			name:   "unmatched backtick is text",
+			source: "a ` b {{< youtube a >}}\n\nc `",
+			want:   "a ` b \n\nc `",
+		},
+	}
+	processor := hugoembedding.NewShortcodeProcessor()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, string(processor.Process([]byte(tt.source))), tt.want)
+		})
+	}
+}
+
+func TestShortcodeCustomRule(t *testing.T) {
+	processor := hugoembedding.NewShortcodeProcessor()
+	processor.Rules["notice"] = hugoembedding.ShortcodeRule{Action: hugoembedding.ShortcodeDrop}
+	processor.Default = hugoembedding.ShortcodeRule{Action: hugoembedding.ShortcodeAttribute, Attributes: []string{"text"}}
+
+	got := processor.Process([]byte(`a{{% notice %}}b{{% /notice %}} {{< button text="Buy" >}}`))
+	assert.Equal(t, string(got), "a Buy")
+}
+
+func TestShortcodeSet(t *testing.T) {
+	processor := hugoembedding.NewShortcodeProcessor()
+	for _, spec := range []string{"notice=drop", "button=attribute:text, title", "*=drop", "tabs=expand"} {
+		assert.NilError(t, processor.Set(spec))
+	}
+	assert.DeepEqual(t, processor.Rules["button"], hugoembedding.ShortcodeRule{Action: hugoembedding.ShortcodeAttribute, Attributes: []string{"text", "title"}})
+	assert.Equal(t, processor.Default.Action, hugoembedding.ShortcodeDrop)
+	got := processor.Process([]byte(`a{{% notice %}}b{{% /notice %}} {{< button title="Buy" >}} {{< toc >}}{{< tabs >}}c{{< /tabs >}}`))
+	assert.Equal(t, string(got), "a Buy c")
+	assert.Assert(t, strings.Contains(processor.String(), "button=attribute:text,title"))
+	assert.Assert(t, strings.HasSuffix(processor.String(), " *=drop"))
+
+	for _, spec := range []string{"notice", "=drop", "notice=hide", "button=attribute"} {
+		assert.ErrorContains(t, processor.Set(spec), "", spec)
+	}
+}
+
+func TestParseShortcodeInCode(t *testing.T) {
+	content := []byte("```markdown\n{{< highlight go >}}\nfunc main() {}\n{{< /highlight >}}\n```\n\n- one\n- two\n\nSee [post]({{< ref \"a.md\" >}}) and `{{< ref \"b.md\" >}}`.\n")
+	chunks, err := hugoembedding.Parse(content, hugoembedding.DefaultConfig())
+	assert.NilError(t, err)
+	kinds := []hugoembedding.ChunkKind{}
+	for _, chunk := range *chunks {
+		kinds = append(kinds, chunk.Kind)
+	}
+	assert.DeepEqual(t, kinds, []hugoembedding.ChunkKind{hugoembedding.ChunkCode, hugoembedding.ChunkList, hugoembedding.ChunkText})
+	assert.Assert(t, strings.Contains(*(*chunks)[0].Chunk, "{{< highlight go >}}"))
+	assert.Assert(t, !strings.Contains(*(*chunks)[0].Chunk, "```"))
+	assert.Equal(t, *(*chunks)[2].Chunk, "See post and {{< ref \"b.md\" >}}.\n")
+	assert.DeepEqual(t, (*chunks)[2].Links, []string{"/a/"})
+}
+
+func TestParseShortcodeConfig(t *testing.T) {
+	cfg := hugoembedding.DefaultConfig()
+	cfg.Shortcodes.Rules["notice"] = hugoembedding.ShortcodeRule{Action: hugoembedding.ShortcodeDrop}
+	content := []byte("Before\n\n{{% notice %}}\nHidden\n{{% /notice %}}\n")
+	chunks, err := hugoembedding.Parse(content, cfg)
+	assert.NilError(t, err)
+	assert.Equal(t, len(*chunks), 1)
+	chunks, err = hugoembedding.Parse(content, hugoembedding.DefaultConfig())
+	assert.NilError(t, err)
+	assert.Equal(t, len(*chunks), 2)
+}
+
+// No chunk of the test corpus may contain shortcode markup
+func TestParseWithoutShortcodes(t *testing.T) {
+	err := filepath.Walk("testdata", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".md") {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		assert.NilError(t, err)
+		chunks, err := hugoembedding.Parse(content, hugoembedding.DefaultConfig())
+		assert.NilError(t, err)
+		for _, chunk := range *chunks {
+			assert.Assert(t, !strings.Contains(*chunk.Chunk, "{{<"), "%s: %s", path, *chunk.Chunk)
+			assert.Assert(t, !strings.Contains(*chunk.Chunk, "{{%"), "%s: %s", path, *chunk.Chunk)
+		}
+		return nil
+	})
+	assert.NilError(t, err)
+}
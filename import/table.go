@@ -0,0 +1,85 @@
+package hugoembedding
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+)
+
+// extractTableRows returns one text per table row which keeps the header
+// with each cell, e.g. "Service: Lambda; Timeout: 15 min", and the link
+// targets of each row
+func extractTableRows(table *east.Table, source []byte) ([]string, [][]string) {
+	var headers []string
+	var rows []string
+	var rowLinks [][]string
+
+	for row := table.FirstChild(); row != nil; row = row.NextSibling() {
+		var cells []string
+		var links []string
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			cellText, cellLinks := extractInlineText(cell, source)
+			cells = append(cells, strings.TrimSpace(cellText))
+			links = append(links, cellLinks...)
+		}
+		if row.Kind() == east.KindTableHeader {
+			headers = cells
+			continue
+		}
+
+		pairs := make([]string, 0, len(cells))
+		for i, cell := range cells {
+			if cell == "" {
+				continue
+			}
+			header := ""
+			if i < len(headers) {
+				header = headers[i]
+			}
+			if header == "" {
+				header = fmt.Sprintf("Column %d", i+1)
+			}
+			pairs = append(pairs, header+": "+cell)
+		}
+		if len(pairs) == 0 {
+			continue
+		}
+		rows = append(rows, strings.Join(pairs, "; ")+"\n")
+		rowLinks = append(rowLinks, links)
+	}
+	return rows, rowLinks
+}
+
+// extractInlineText returns the text and the link targets of all inline
+// children of the node
+func extractInlineText(node ast.Node, source []byte) (string, []string) {
+	var buffer bytes.Buffer
+	var links []string
+	_ = ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Text:
+			buffer.Write(n.Text(source))
+			if n.SoftLineBreak() {
+				buffer.WriteByte(' ')
+			}
+		case *ast.String:
+			buffer.Write(n.Value)
+		case *ast.Link:
+			links = append(links, string(n.Destination))
+		case *ast.AutoLink:
+			url := string(n.URL(source))
+			buffer.WriteString(url)
+			links = append(links, url)
+		case *ast.Image, *east.Strikethrough:
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return buffer.String(), links
+}
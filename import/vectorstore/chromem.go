@@ -0,0 +1,169 @@
+package vectorstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// CollectionName is the chromem collection of the documents
+const CollectionName = "knowledge-base"
+
+// Chromem stores the documents in memory and exports them to a file
+type Chromem struct {
+	db         *chromem.DB
+	collection *chromem.Collection
+	// store writes the database on Close, nil for a read only store
+	store func(*chromem.DB) error
+	// dims are the dimensions of the embeddings, 0 until known
+	dims int
+}
+
+// NewChromem wraps a database created by localstore.Init or loaded by
+// localstore.Load. Close writes the database with store, e.g. with
+// localstore.Store, a nil store never writes the database.
+func NewChromem(db *chromem.DB, store func(*chromem.DB) error) (*Chromem, error) {
+	collection := db.GetCollection(CollectionName, nil)
+	if collection == nil {
+		return nil, fmt.Errorf("database has no %v collection", CollectionName)
+	}
+	return &Chromem{db: db, collection: collection, store: store}, nil
+}
+
+// DB is the wrapped chromem database
+func (c *Chromem) DB() *chromem.DB {
+	return c.db
+}
+
+func (c *Chromem) Upsert(ctx context.Context, documents []Document) error {
+	docs := make([]chromem.Document, len(documents))
+	for i, document := range documents {
+		docs[i] = chromem.Document{
+			ID:        document.ID,
+			Content:   document.Content,
+			Embedding: document.Embedding,
+			Metadata:  document.Metadata,
+		}
+	}
+	if len(documents) > 0 && len(documents[0].Embedding) > 0 {
+		c.dims = len(documents[0].Embedding)
+	}
+	// chromem replaces documents with the same ID
+	return c.collection.AddDocuments(ctx, docs, runtime.NumCPU())
+}
+
+func (c *Chromem) DeleteBySource(ctx context.Context, source string) error {
+	if c.collection.Count() == 0 {
+		return nil
+	}
+	return c.collection.Delete(ctx, map[string]string{"source": filepath.ToSlash(source)}, nil)
+}
+
+func (c *Chromem) Query(ctx context.Context, embedding []float32, k int, filter map[string]string) ([]Result, error) {
+	// chromem fails if more documents are requested than stored
+	k = min(k, c.collection.Count())
+	if k == 0 {
+		return nil, nil
+	}
+	res, err := c.collection.QueryEmbedding(ctx, embedding, k, filter, nil)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Result, len(res))
+	for i, r := range res {
+		results[i] = Result{
+			Document: Document{
+				ID:        r.ID,
+				Content:   r.Content,
+				Embedding: r.Embedding,
+				Metadata:  r.Metadata,
+			},
+			Similarity: r.Similarity,
+		}
+	}
+	return results, nil
+}
+
+func (c *Chromem) Get(ctx context.Context, id string) (*Document, error) {
+	if id == "" {
+		return nil, errors.New("document ID is empty")
+	}
+	doc, err := c.collection.GetByID(ctx, id)
+	switch {
+	// chromem has no error value for a missing document, the ID is not empty
+	case err != nil && strings.Contains(err.Error(), "not found"):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	return &Document{ID: doc.ID, Content: doc.Content, Embedding: doc.Embedding, Metadata: doc.Metadata}, nil
+}
+
+// GetMany gets the documents one by one, they are in memory
+func (c *Chromem) GetMany(ctx context.Context, ids []string) (map[string]*Document, error) {
+	documents := map[string]*Document{}
+	for _, id := range ids {
+		document, err := c.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if document != nil {
+			documents[id] = document
+		}
+	}
+	return documents, nil
+}
+
+func (c *Chromem) Count(ctx context.Context) (int, error) {
+	return c.collection.Count(), nil
+}
+
+// SetDimensions sets the dimensions of the embeddings of a loaded
+// database, e.g. from its manifest
+func (c *Chromem) SetDimensions(dimensions int) {
+	c.dims = dimensions
+}
+
+// Iterate queries all documents, chromem has no other way to list them.
+// The query needs the dimensions of the embeddings, they are known from
+// Upsert or SetDimensions.
+func (c *Chromem) Iterate(ctx context.Context, fn func(Document) error) error {
+	count := c.collection.Count()
+	if count == 0 {
+		return nil
+	}
+	if c.dims == 0 {
+		return errors.New("dimensions of the chromem database are unknown")
+	}
+	query := make([]float32, c.dims)
+	query[0] = 1
+	res, err := c.collection.QueryEmbedding(ctx, query, count, nil, nil)
+	if err != nil {
+		return err
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	for _, r := range res {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := fn(Document{ID: r.ID, Content: r.Content, Embedding: r.Embedding, Metadata: r.Metadata})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close writes the database
+func (c *Chromem) Close() error {
+	if c.store == nil {
+		return nil
+	}
+	return c.store(c.db)
+}
@@ -0,0 +1,108 @@
+package vectorstore_test
+
+import (
+	"context"
+	"hugoembedding/embedding"
+	"hugoembedding/internal/testdb"
+	"hugoembedding/vectorstore"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestChromem(t *testing.T) {
+	ctx := context.Background()
+	db := testdb.New(t, 64)
+	store := db.Store
+
+	texts := []struct {
+		id, source, content string
+	}{
+		{"b-0000", "b.md", "Scaling down EKS clusters at night"},
+		{"a-0000", "a.md", "Finding EBS boot volumes"},
+		{"a-0001", "a.md", "Boot volumes of instances are EBS volumes"},
+	}
+	var documents []vectorstore.Document
+	for _, text := range texts {
+		documents = append(documents, db.Document(t, text.id, text.source, text.content))
+	}
+	assert.NilError(t, store.Upsert(ctx, documents))
+	// the same ID replaces the document
+	documents[0].Content = "Scaling down EKS clusters"
+	assert.NilError(t, store.Upsert(ctx, documents[:1]))
+	count, err := store.Count(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, count, 3)
+
+	document, err := store.Get(ctx, "b-0000")
+	assert.NilError(t, err)
+	assert.Equal(t, document.Content, "Scaling down EKS clusters")
+	document, err = store.Get(ctx, "c-0000")
+	assert.NilError(t, err)
+	assert.Assert(t, document == nil)
+	_, err = store.Get(ctx, "")
+	assert.ErrorContains(t, err, "document ID is empty")
+	many, err := store.GetMany(ctx, []string{"a-0001", "c-0000", "b-0000"})
+	assert.NilError(t, err)
+	assert.Equal(t, len(many), 2)
+	assert.Equal(t, many["a-0001"].Content, "Boot volumes of instances are EBS volumes")
+
+	question, err := embedding.Embed(ctx, db.Embedder, "EBS boot volumes")
+	assert.NilError(t, err)
+	tests := []struct {
+		name   string
+		k      int
+		filter map[string]string
+		ids    []string
+	}{
+		{"best", 1, nil, []string{"a-0000"}},
+		{"more than stored", 10, nil, []string{"a-0000", "a-0001", "b-0000"}},
+		{"filter", 10, map[string]string{"source": "b.md"}, []string{"b-0000"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			results, err := store.Query(ctx, question, test.k, test.filter)
+			assert.NilError(t, err)
+			ids := []string{}
+			for _, result := range results {
+				ids = append(ids, result.ID)
+			}
+			if len(ids) > 1 {
+				// the order of the less similar documents is not checked
+				assert.Equal(t, ids[0], test.ids[0])
+				assert.Equal(t, len(ids), len(test.ids))
+				return
+			}
+			assert.DeepEqual(t, ids, test.ids)
+		})
+	}
+
+	// the documents are iterated ordered by ID
+	assert.NilError(t, store.DeleteBySource(ctx, "b.md"))
+	ids := []string{}
+	assert.NilError(t, store.Iterate(ctx, func(document vectorstore.Document) error {
+		ids = append(ids, document.ID)
+		return nil
+	}))
+	assert.DeepEqual(t, ids, []string{"a-0000", "a-0001"})
+
+	// Close exports the database
+	assert.NilError(t, store.Close())
+	loaded := db.Load(t)
+	count, err = loaded.Count(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, count, 2)
+	// a loaded database is iterated with the dimensions of the manifest
+	documents = nil
+	assert.NilError(t, loaded.Iterate(ctx, func(document vectorstore.Document) error {
+		documents = append(documents, document)
+		return nil
+	}))
+	assert.Equal(t, len(documents), 2)
+	assert.Equal(t, documents[1].ID, "a-0001")
+	assert.Equal(t, len(documents[1].Embedding), 64)
+	unknown, err := vectorstore.NewChromem(loaded.DB(), nil)
+	assert.NilError(t, err)
+	err = unknown.Iterate(ctx, func(document vectorstore.Document) error { return nil })
+	assert.Error(t, err, "dimensions of the chromem database are unknown")
+}
@@ -0,0 +1,168 @@
+package vectorstore
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// jsonlDocument is one line of a JSONL export
+type jsonlDocument struct {
+	ID        string            `json:"id"`
+	Content   string            `json:"content"`
+	Metadata  map[string]string `json:"metadata"`
+	Embedding []float32         `json:"embedding"`
+}
+
+// JSONL is an export of the documents with one JSON document per line.
+// A JSONL file is either written with CreateJSONL or read with OpenJSONL.
+type JSONL struct {
+	file   *os.File
+	writer *bufio.Writer
+	// lines are the offset and length of the last line of each ID
+	lines map[string]jsonlLine
+}
+
+type jsonlLine struct {
+	offset int64
+	length int
+}
+
+// CreateJSONL creates the file, Upsert appends the documents
+func CreateJSONL(path string) (*JSONL, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONL{file: file, writer: bufio.NewWriter(file)}, nil
+}
+
+// OpenJSONL reads the IDs of the file, the documents are read when used.
+// A later line replaces an earlier line with the same ID.
+func OpenJSONL(path string) (*JSONL, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	store := &JSONL{file: file, lines: map[string]jsonlLine{}}
+	reader := bufio.NewReader(file)
+	var offset int64
+	for number := 1; ; number++ {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var document struct {
+				ID string `json:"id"`
+			}
+			if err := json.Unmarshal(line, &document); err != nil {
+				file.Close()
+				return nil, fmt.Errorf("%v line %d: %w", path, number, err)
+			}
+			store.lines[document.ID] = jsonlLine{offset: offset, length: len(line)}
+			offset += int64(len(line))
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+func (j *JSONL) Upsert(ctx context.Context, documents []Document) error {
+	if j.writer == nil {
+		return errors.New("jsonl file is opened for reading")
+	}
+	encoder := json.NewEncoder(j.writer)
+	for _, document := range documents {
+		err := encoder.Encode(jsonlDocument{
+			ID:        document.ID,
+			Content:   document.Content,
+			Metadata:  document.Metadata,
+			Embedding: document.Embedding,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (j *JSONL) Get(ctx context.Context, id string) (*Document, error) {
+	line, ok := j.lines[id]
+	if !ok {
+		return nil, nil
+	}
+	data := make([]byte, line.length)
+	if _, err := j.file.ReadAt(data, line.offset); err != nil {
+		return nil, err
+	}
+	var document jsonlDocument
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("document %v: %w", id, err)
+	}
+	return &Document{
+		ID:        document.ID,
+		Content:   document.Content,
+		Embedding: document.Embedding,
+		Metadata:  document.Metadata,
+	}, nil
+}
+
+func (j *JSONL) GetMany(ctx context.Context, ids []string) (map[string]*Document, error) {
+	documents := map[string]*Document{}
+	for _, id := range ids {
+		document, err := j.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if document != nil {
+			documents[id] = document
+		}
+	}
+	return documents, nil
+}
+
+func (j *JSONL) Count(ctx context.Context) (int, error) {
+	return len(j.lines), nil
+}
+
+// Iterate reads the documents ordered by ID
+func (j *JSONL) Iterate(ctx context.Context, fn func(Document) error) error {
+	ids := make([]string, 0, len(j.lines))
+	for id := range j.lines {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		document, err := j.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := fn(*document); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close writes the buffered lines of a created file
+func (j *JSONL) Close() error {
+	if j.writer != nil {
+		if err := j.writer.Flush(); err != nil {
+			j.file.Close()
+			return err
+		}
+	}
+	return j.file.Close()
+}
@@ -0,0 +1,189 @@
+package vectorstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Reader is the part of a store a migration reads from, the stores and
+// a JSONL file opened with OpenJSONL
+type Reader interface {
+	Get(ctx context.Context, id string) (*Document, error)
+	Count(ctx context.Context) (int, error)
+	Iterate(ctx context.Context, fn func(Document) error) error
+	Close() error
+}
+
+// Writer is the part of a store a migration writes to, the stores and a
+// JSONL file created with CreateJSONL
+type Writer interface {
+	Upsert(ctx context.Context, documents []Document) error
+	Close() error
+}
+
+// DefaultMigrateBatch is the number of documents written at once
+const DefaultMigrateBatch = 500
+
+// MinMigratedSimilarity is the cosine similarity a migrated embedding
+// must keep, the int8 encoding of SQLite changes the vectors a little
+const MinMigratedSimilarity = 0.999
+
+// Copy writes all documents of the reader to the writer with their
+// embeddings and returns the number of documents
+func Copy(ctx context.Context, from Reader, to Writer, batchSize int) (int, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultMigrateBatch
+	}
+	copied := 0
+	batch := make([]Document, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := to.Upsert(ctx, batch); err != nil {
+			return err
+		}
+		copied += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+	err := from.Iterate(ctx, func(document Document) error {
+		if len(document.Embedding) == 0 {
+			return fmt.Errorf("document %v has no embedding", document.ID)
+		}
+		batch = append(batch, document)
+		if len(batch) == batchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	return copied, err
+}
+
+// errStop ends an iteration early
+var errStop = errors.New("stop")
+
+// Dimensions returns the size of the first embedding, 0 for an empty store
+func Dimensions(ctx context.Context, from Reader) (int, error) {
+	dimensions := 0
+	err := from.Iterate(ctx, func(document Document) error {
+		dimensions = len(document.Embedding)
+		return errStop
+	})
+	if err != nil && !errors.Is(err, errStop) {
+		return 0, err
+	}
+	return dimensions, nil
+}
+
+// Checksum identifies the documents of a store independent of their order
+type Checksum struct {
+	Count int
+	// Sum covers the IDs, the contents and the metadata. The embeddings
+	// are not included, the stores normalize or quantize them.
+	Sum string
+}
+
+// Checksums reads all documents of the store
+func Checksums(ctx context.Context, from Reader) (Checksum, error) {
+	var sum [sha256.Size]byte
+	count := 0
+	err := from.Iterate(ctx, func(document Document) error {
+		hash := documentHash(document)
+		for i := range sum {
+			sum[i] ^= hash[i]
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return Checksum{}, err
+	}
+	return Checksum{Count: count, Sum: hex.EncodeToString(sum[:])}, nil
+}
+
+// documentHash hashes the fields with their lengths, the metadata
+// ordered by key
+func documentHash(document Document) [sha256.Size]byte {
+	hash := sha256.New()
+	write := func(value string) {
+		binary.Write(hash, binary.LittleEndian, uint64(len(value)))
+		hash.Write([]byte(value))
+	}
+	write(document.ID)
+	write(document.Content)
+	keys := make([]string, 0, len(document.Metadata))
+	for key := range document.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		write(key)
+		write(document.Metadata[key])
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], hash.Sum(nil))
+	return sum
+}
+
+// Verify compares the migrated store with the source: the counts, the
+// checksums and each embedding of the source with the migrated embedding
+func Verify(ctx context.Context, from, to Reader) error {
+	expected, err := Checksums(ctx, from)
+	if err != nil {
+		return err
+	}
+	actual, err := Checksums(ctx, to)
+	if err != nil {
+		return err
+	}
+	if expected.Count != actual.Count {
+		return fmt.Errorf("migrated %d documents, the source has %d", actual.Count, expected.Count)
+	}
+	if expected.Sum != actual.Sum {
+		return fmt.Errorf("checksum %v of the migrated documents differs from %v", actual.Sum, expected.Sum)
+	}
+	return from.Iterate(ctx, func(document Document) error {
+		migrated, err := to.Get(ctx, document.ID)
+		if err != nil {
+			return err
+		}
+		if migrated == nil {
+			return fmt.Errorf("document %v was not migrated", document.ID)
+		}
+		if len(migrated.Embedding) != len(document.Embedding) {
+			return fmt.Errorf("document %v has %d dimensions, the source %d",
+				document.ID, len(migrated.Embedding), len(document.Embedding))
+		}
+		if similarity := cosine(document.Embedding, migrated.Embedding); similarity < MinMigratedSimilarity {
+			return fmt.Errorf("embedding of document %v changed, similarity %v", document.ID, similarity)
+		}
+		return nil
+	})
+}
+
+func cosine(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		// two zero vectors are equal
+		if normA == normB {
+			return 1
+		}
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
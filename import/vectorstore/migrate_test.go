@@ -0,0 +1,91 @@
+package vectorstore_test
+
+import (
+	"context"
+	"fmt"
+	"hugoembedding/internal/testdb"
+	"hugoembedding/vectorstore"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	db := testdb.New(t, 64)
+	source := db.Store
+
+	var documents []vectorstore.Document
+	for i, content := range []string{"Finding EBS boot volumes", "Scaling down EKS clusters at night", "Budgets of AWS accounts"} {
+		document := db.Document(t, fmt.Sprintf("post-%04d", i), "post.md", content)
+		document.Metadata["title"] = "Post"
+		documents = append(documents, document)
+	}
+	assert.NilError(t, source.Upsert(ctx, documents))
+	expected, err := vectorstore.Checksums(ctx, source)
+	assert.NilError(t, err)
+	assert.Equal(t, expected.Count, 3)
+
+	// chromem => jsonl => sqlite => jsonl, the batch is smaller than the store
+	jsonlPath := filepath.Join(dir, "export.jsonl")
+	exported, err := vectorstore.CreateJSONL(jsonlPath)
+	assert.NilError(t, err)
+	copied, err := vectorstore.Copy(ctx, source, exported, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, copied, 3)
+	assert.NilError(t, exported.Close())
+	jsonl, err := vectorstore.OpenJSONL(jsonlPath)
+	assert.NilError(t, err)
+	defer jsonl.Close()
+	assert.NilError(t, vectorstore.Verify(ctx, source, jsonl))
+
+	tests := []struct {
+		name     string
+		encoding string
+	}{
+		{"float32", vectorstore.EncodingFloat32},
+		{"int8", vectorstore.EncodingInt8},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sqlite, err := vectorstore.OpenSQLite(filepath.Join(dir, test.name+".sqlite"), vectorstore.SQLiteOptions{Encoding: test.encoding}, ctx)
+			assert.NilError(t, err)
+			defer sqlite.Close()
+			_, err = vectorstore.Copy(ctx, jsonl, sqlite, 0)
+			assert.NilError(t, err)
+			assert.NilError(t, vectorstore.Verify(ctx, jsonl, sqlite))
+
+			back := filepath.Join(dir, test.name+".jsonl")
+			exported, err := vectorstore.CreateJSONL(back)
+			assert.NilError(t, err)
+			_, err = vectorstore.Copy(ctx, sqlite, exported, 0)
+			assert.NilError(t, err)
+			assert.NilError(t, exported.Close())
+			migrated, err := vectorstore.OpenJSONL(back)
+			assert.NilError(t, err)
+			defer migrated.Close()
+			actual, err := vectorstore.Checksums(ctx, migrated)
+			assert.NilError(t, err)
+			assert.Equal(t, actual, expected)
+			assert.NilError(t, vectorstore.Verify(ctx, source, migrated))
+		})
+	}
+
+	// a changed document fails the verification
+	changed, err := vectorstore.CreateJSONL(filepath.Join(dir, "changed.jsonl"))
+	assert.NilError(t, err)
+	_, err = vectorstore.Copy(ctx, source, changed, 0)
+	assert.NilError(t, err)
+	documents[1].Content = "Scaling down EKS clusters"
+	assert.NilError(t, changed.Upsert(ctx, documents[1:2]))
+	assert.NilError(t, changed.Close())
+	migrated, err := vectorstore.OpenJSONL(filepath.Join(dir, "changed.jsonl"))
+	assert.NilError(t, err)
+	defer migrated.Close()
+	count, err := migrated.Count(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, count, 3)
+	assert.ErrorContains(t, vectorstore.Verify(ctx, source, migrated), "checksum")
+}
@@ -0,0 +1,127 @@
+package vectorstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const defaultRegion = "eu-central-1"
+
+// PoolMaxConns limits the connections of a lambda instance, an instance
+// handles one request at a time
+const PoolMaxConns = 2
+
+// ConnectPostgresPool creates a connection pool for the database URL.
+// The connections are opened on first use and reused by the following
+// invocations of a warm lambda instance.
+func ConnectPostgresPool(url string, options PostgresOptions, ctx context.Context) (*Postgres, error) {
+	if url == "" {
+		return nil, errors.New("pgvector needs a DATABASE_URL or DATABASE_SECRET_ARN")
+	}
+	poolConfig, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		return nil, err
+	}
+	// pool_max_conns in the URL overrides the default
+	if !hasParameter(url, "pool_max_conns") {
+		poolConfig.MaxConns = PoolMaxConns
+	}
+	// a frozen lambda instance keeps stale connections
+	poolConfig.MaxConnIdleTime = 5 * time.Minute
+	poolConfig.HealthCheckPeriod = time.Minute
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, err
+	}
+	store := NewPostgres(pool, options)
+	store.close = func() error {
+		pool.Close()
+		return nil
+	}
+	return store, nil
+}
+
+func hasParameter(databaseURL string, name string) bool {
+	parsed, err := url.Parse(databaseURL)
+	if err != nil {
+		return false
+	}
+	return parsed.Query().Has(name)
+}
+
+// databaseSecret is the secret of an RDS database in Secrets Manager
+type databaseSecret struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Host     string `json:"host"`
+	Port     any    `json:"port"`
+	DBName   string `json:"dbname"`
+}
+
+// DatabaseURLFromEnv returns DATABASE_URL or reads the credentials from
+// the Secrets Manager secret DATABASE_SECRET_ARN. The secret is a
+// connection string or the JSON of an RDS database secret.
+func DatabaseURLFromEnv(ctx context.Context) (string, error) {
+	if databaseURL := os.Getenv("DATABASE_URL"); databaseURL != "" {
+		return databaseURL, nil
+	}
+	secretID := os.Getenv("DATABASE_SECRET_ARN")
+	if secretID == "" {
+		return "", nil
+	}
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = defaultRegion
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return "", err
+	}
+	client := secretsmanager.NewFromConfig(cfg)
+	output, err := client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(secretID)})
+	if err != nil {
+		return "", fmt.Errorf("reading database secret: %w", err)
+	}
+	return secretDatabaseURL(aws.ToString(output.SecretString))
+}
+
+// secretDatabaseURL builds the connection string from the secret
+func secretDatabaseURL(secret string) (string, error) {
+	if !json.Valid([]byte(secret)) {
+		return secret, nil
+	}
+	var credentials databaseSecret
+	if err := json.Unmarshal([]byte(secret), &credentials); err != nil {
+		return "", fmt.Errorf("decoding database secret: %w", err)
+	}
+	if credentials.Host == "" {
+		return "", errors.New("database secret has no host")
+	}
+	host := credentials.Host
+	switch port := credentials.Port.(type) {
+	case float64:
+		host += ":" + strconv.Itoa(int(port))
+	case string:
+		if port != "" {
+			host += ":" + port
+		}
+	}
+	databaseURL := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(credentials.Username, credentials.Password),
+		Host:   host,
+		Path:   "/" + credentials.DBName,
+	}
+	return databaseURL.String(), nil
+}
@@ -0,0 +1,196 @@
+package vectorstore_test
+
+import (
+	"context"
+	"fmt"
+	"hugoembedding/embedding"
+	"hugoembedding/vectorstore"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gotest.tools/v3/assert"
+)
+
+// connectTest connects to the database of PGVECTOR_TEST_URL, e.g. the
+// container of `task postgres`, the test is skipped without it
+func connectTest(t *testing.T, options vectorstore.PostgresOptions) *vectorstore.Postgres {
+	url := os.Getenv("PGVECTOR_TEST_URL")
+	if url == "" {
+		t.Skip("PGVECTOR_TEST_URL is not set")
+	}
+	ctx := context.Background()
+	store, err := vectorstore.ConnectPostgres(url, options, ctx)
+	assert.NilError(t, err)
+	t.Cleanup(func() {
+		assert.NilError(t, store.Drop(ctx))
+		assert.NilError(t, store.Close())
+	})
+	return store
+}
+
+func testTable(name string) string {
+	return fmt.Sprintf("test_%v_%d", name, time.Now().UnixNano())
+}
+
+func TestPostgres(t *testing.T) {
+	tests := []struct {
+		name  string
+		index string
+	}{
+		{"hnsw", vectorstore.IndexHNSW},
+		{"ivfflat", vectorstore.IndexIVFFlat},
+		{"none", vectorstore.IndexNone},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			embedder, err := embedding.NewHashing(64)
+			assert.NilError(t, err)
+			options := vectorstore.DefaultPostgresOptions
+			options.Table = testTable(test.name)
+			options.Dimensions = embedder.Dimensions()
+			options.Index = test.index
+			options.BatchSize = 2
+			store := connectTest(t, options)
+
+			// migrations are applied once
+			assert.NilError(t, store.Migrate(ctx))
+			assert.NilError(t, store.Migrate(ctx))
+
+			texts := []struct {
+				id, source, content string
+			}{
+				{"b-0000", "b.md", "Scaling down EKS clusters at night"},
+				{"a-0000", "a.md", "Finding EBS boot volumes"},
+				{"a-0001", "a.md", "Boot volumes of instances are EBS volumes"},
+			}
+			var documents []vectorstore.Document
+			for _, text := range texts {
+				vector, err := embedding.Embed(ctx, embedder, text.content)
+				assert.NilError(t, err)
+				documents = append(documents, vectorstore.Document{
+					ID:        text.id,
+					Content:   text.content,
+					Embedding: vector,
+					Metadata:  map[string]string{"source": text.source},
+				})
+			}
+			assert.NilError(t, store.Upsert(ctx, documents))
+			// the same ID replaces the document
+			documents[0].Content = "Scaling down EKS clusters"
+			assert.NilError(t, store.Upsert(ctx, documents[:1]))
+			assert.NilError(t, store.CreateIndex(ctx))
+			count, err := store.Count(ctx)
+			assert.NilError(t, err)
+			assert.Equal(t, count, 3)
+
+			document, err := store.Get(ctx, "b-0000")
+			assert.NilError(t, err)
+			assert.Equal(t, document.Content, "Scaling down EKS clusters")
+			assert.Equal(t, document.Metadata["source"], "b.md")
+			assert.Equal(t, len(document.Embedding), 64)
+			document, err = store.Get(ctx, "c-0000")
+			assert.NilError(t, err)
+			assert.Assert(t, document == nil)
+			many, err := store.GetMany(ctx, []string{"a-0001", "c-0000", "b-0000"})
+			assert.NilError(t, err)
+			assert.Equal(t, len(many), 2)
+			assert.Equal(t, many["b-0000"].Content, "Scaling down EKS clusters")
+			assert.Equal(t, many["b-0000"].Metadata["source"], "b.md")
+
+			question, err := embedding.Embed(ctx, embedder, "EBS boot volumes")
+			assert.NilError(t, err)
+			results, err := store.Query(ctx, question, 10, map[string]string{"source": "a.md"})
+			assert.NilError(t, err)
+			assert.Equal(t, len(results), 2)
+			assert.Equal(t, results[0].ID, "a-0000")
+			assert.Assert(t, results[0].Similarity > results[1].Similarity)
+
+			assert.NilError(t, store.DeleteBySource(ctx, "b.md"))
+			ids := []string{}
+			assert.NilError(t, store.Iterate(ctx, func(document vectorstore.Document) error {
+				ids = append(ids, document.ID)
+				return nil
+			}))
+			assert.DeepEqual(t, ids, []string{"a-0000", "a-0001"})
+
+			assert.NilError(t, store.Truncate(ctx))
+			count, err = store.Count(ctx)
+			assert.NilError(t, err)
+			assert.Equal(t, count, 0)
+		})
+	}
+}
+
+func TestPostgresDimensions(t *testing.T) {
+	ctx := context.Background()
+	options := vectorstore.DefaultPostgresOptions
+	options.Table = testTable("dimensions")
+	options.Dimensions = 64
+	store := connectTest(t, options)
+	assert.NilError(t, store.Migrate(ctx))
+
+	// the table of another embedding model is not used
+	options.Dimensions = 32
+	other := connectTest(t, options)
+	assert.ErrorIs(t, other.Migrate(ctx), vectorstore.ErrDimensionMismatch)
+}
+
+func TestPostgresManifest(t *testing.T) {
+	ctx := context.Background()
+	options := vectorstore.DefaultPostgresOptions
+	options.Table = testTable("manifest")
+	options.Dimensions = 64
+	store := connectTest(t, options)
+	manifest, err := store.ReadManifest(ctx)
+	assert.NilError(t, err)
+	assert.Assert(t, manifest == nil)
+	assert.NilError(t, store.Migrate(ctx))
+
+	assert.NilError(t, store.WriteManifest(ctx, []byte(`{"model": "local/hashing-64"}`)))
+	assert.NilError(t, store.WriteManifest(ctx, []byte(`{"model": "local/hashing-32"}`)))
+	manifest, err = store.ReadManifest(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, string(manifest), `{"model": "local/hashing-32"}`)
+
+	// the manifest is removed with the documents
+	assert.NilError(t, store.Truncate(ctx))
+	manifest, err = store.ReadManifest(ctx)
+	assert.NilError(t, err)
+	assert.Assert(t, manifest == nil)
+}
+
+func TestPostgresUnversionedTable(t *testing.T) {
+	ctx := context.Background()
+	options := vectorstore.DefaultPostgresOptions
+	options.Table = testTable("legacy")
+	options.Dimensions = 64
+	store := connectTest(t, options)
+	// the table of the first importer
+	conn, err := pgx.Connect(ctx, os.Getenv("PGVECTOR_TEST_URL"))
+	assert.NilError(t, err)
+	defer conn.Close(ctx)
+	_, err = conn.Exec(ctx, "CREATE EXTENSION IF NOT EXISTS vector")
+	assert.NilError(t, err)
+	_, err = conn.Exec(ctx, "CREATE TABLE "+options.Table+" (id bigserial PRIMARY KEY, content text, context text, link text, title text, embedding vector(64))")
+	assert.NilError(t, err)
+
+	assert.ErrorIs(t, store.Migrate(ctx), vectorstore.ErrUnversionedTable)
+}
+
+func TestIVFFlatLists(t *testing.T) {
+	tests := []struct {
+		rows  int
+		lists int
+	}{
+		{0, 1},
+		{5000, 5},
+		{1000000, 1000},
+		{4000000, 2000},
+	}
+	for _, test := range tests {
+		assert.Equal(t, vectorstore.IVFFlatLists(test.rows), test.lists, "rows %d", test.rows)
+	}
+}
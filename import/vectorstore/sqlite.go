@@ -0,0 +1,411 @@
+package vectorstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	he "hugoembedding"
+
+	// pure Go SQLite, the lambda is built without cgo
+	_ "modernc.org/sqlite"
+)
+
+const (
+	// EncodingFloat32 stores the vectors as float32
+	EncodingFloat32 = "float32"
+	// EncodingInt8 quantizes the vectors to int8 with a scale per
+	// vector, the file is about four times smaller
+	EncodingInt8 = "int8"
+)
+
+// SQLiteOptions configure a SQLite store
+type SQLiteOptions struct {
+	// Encoding of new vectors, an existing file keeps its encoding
+	Encoding string
+	// ReadOnly opens the file without locking, e.g. in the lambda
+	ReadOnly bool
+}
+
+// DefaultSQLiteOptions store float32 vectors
+var DefaultSQLiteOptions = SQLiteOptions{Encoding: EncodingFloat32}
+
+// sqliteSchemaVersion is the version of the tables of the file
+const sqliteSchemaVersion = "1"
+
+// SQLite stores the documents, the metadata and the vectors in one file.
+// A query scores all documents which match the filter, the vectors are
+// normalized when stored so the dot product is the cosine similarity.
+type SQLite struct {
+	db       *sql.DB
+	encoding string
+}
+
+// OpenSQLite opens or creates the database file
+func OpenSQLite(path string, options SQLiteOptions, ctx context.Context) (*SQLite, error) {
+	dsn := "file:" + filepath.ToSlash(path)
+	if options.ReadOnly {
+		// immutable skips the locks, the lambda file system is read only
+		dsn += "?mode=ro&immutable=1"
+	}
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	store := &SQLite{db: db, encoding: options.Encoding}
+	if store.encoding == "" {
+		store.encoding = EncodingFloat32
+	}
+	if !options.ReadOnly {
+		err = store.createTables(ctx)
+	}
+	if err == nil {
+		err = store.readSettings(ctx)
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *SQLite) createTables(ctx context.Context) error {
+	statements := []string{
+		"CREATE TABLE IF NOT EXISTS settings (key TEXT PRIMARY KEY, value TEXT NOT NULL)",
+		"CREATE TABLE IF NOT EXISTS documents (id TEXT PRIMARY KEY, content TEXT NOT NULL, source TEXT, metadata TEXT NOT NULL, embedding BLOB NOT NULL)",
+		"CREATE INDEX IF NOT EXISTS documents_source_idx ON documents (source)",
+	}
+	for _, statement := range statements {
+		if _, err := s.db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	// a new file gets the settings of the options
+	_, err := s.db.ExecContext(ctx, "INSERT OR IGNORE INTO settings (key, value) VALUES ('schema_version', ?), ('encoding', ?)",
+		sqliteSchemaVersion, s.encoding)
+	return err
+}
+
+func (s *SQLite) readSettings(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, "SELECT key, value FROM settings")
+	if err != nil {
+		return fmt.Errorf("reading sqlite settings: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return err
+		}
+		switch key {
+		case "schema_version":
+			if value != sqliteSchemaVersion {
+				return fmt.Errorf("sqlite schema version %v, expected %v", value, sqliteSchemaVersion)
+			}
+		case "encoding":
+			if value != s.encoding {
+				he.Logger.Info("Using the vector encoding of the file", "encoding", value)
+			}
+			s.encoding = value
+		}
+	}
+	return rows.Err()
+}
+
+func (s *SQLite) Upsert(ctx context.Context, documents []Document) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	statement, err := tx.PrepareContext(ctx, `INSERT INTO documents (id, content, source, metadata, embedding) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET content = excluded.content, source = excluded.source,
+			metadata = excluded.metadata, embedding = excluded.embedding`)
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+	for _, document := range documents {
+		metadata, err := json.Marshal(document.Metadata)
+		if err != nil {
+			return err
+		}
+		embedding, err := encodeVector(document.Embedding, s.encoding)
+		if err != nil {
+			return err
+		}
+		_, err = statement.ExecContext(ctx, document.ID, document.Content, document.Metadata["source"], string(metadata), embedding)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite) DeleteBySource(ctx context.Context, source string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM documents WHERE source = ?", filepath.ToSlash(source))
+	return err
+}
+
+// Query scores the vectors of all documents which match the filter and
+// reads the k best documents
+func (s *SQLite) Query(ctx context.Context, embedding []float32, k int, filter map[string]string) ([]Result, error) {
+	if k <= 0 {
+		return nil, nil
+	}
+	question := normalize(embedding)
+	where, args := sqliteFilter(filter)
+	rows, err := s.db.QueryContext(ctx, "SELECT id, embedding FROM documents"+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	// best holds the k most similar documents, the best first
+	type scored struct {
+		id         string
+		similarity float32
+	}
+	best := make([]scored, 0, k+1)
+	for rows.Next() {
+		var id string
+		var blob []byte
+		if err := rows.Scan(&id, &blob); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		similarity, err := dotEncoded(question, blob, s.encoding)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("document %v: %w", id, err)
+		}
+		if len(best) == k && similarity <= best[k-1].similarity {
+			continue
+		}
+		i := sort.Search(len(best), func(i int) bool { return best[i].similarity < similarity })
+		best = append(best, scored{})
+		copy(best[i+1:], best[i:])
+		best[i] = scored{id, similarity}
+		best = best[:min(len(best), k)]
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	results := make([]Result, 0, len(best))
+	for _, hit := range best {
+		document, err := s.Get(ctx, hit.id)
+		if err != nil {
+			return nil, err
+		}
+		if document == nil {
+			continue
+		}
+		results = append(results, Result{Document: *document, Similarity: hit.similarity})
+	}
+	return results, nil
+}
+
+// sqliteFilter is the where clause of the metadata filter
+func sqliteFilter(filter map[string]string) (string, []any) {
+	if len(filter) == 0 {
+		return "", nil
+	}
+	keys := make([]string, 0, len(filter))
+	for key := range filter {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	conditions := make([]string, len(keys))
+	args := make([]any, 0, 2*len(keys))
+	for i, key := range keys {
+		conditions[i] = "json_extract(metadata, ?) = ?"
+		args = append(args, `$."`+strings.ReplaceAll(key, `"`, `\"`)+`"`, filter[key])
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func (s *SQLite) Get(ctx context.Context, id string) (*Document, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT id, content, metadata, embedding FROM documents WHERE id = ?", id)
+	document, err := s.scanDocument(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+func (s *SQLite) GetMany(ctx context.Context, ids []string) (map[string]*Document, error) {
+	documents := map[string]*Document{}
+	if len(ids) == 0 {
+		return documents, nil
+	}
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	rows, err := s.db.QueryContext(ctx, "SELECT id, content, metadata, embedding FROM documents WHERE id IN ("+placeholders+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		document, err := s.scanDocument(rows)
+		if err != nil {
+			return nil, err
+		}
+		documents[document.ID] = document
+	}
+	return documents, rows.Err()
+}
+
+func (s *SQLite) Count(ctx context.Context) (int, error) {
+	count := 0
+	err := s.db.QueryRowContext(ctx, "SELECT count(*) FROM documents").Scan(&count)
+	return count, err
+}
+
+func (s *SQLite) Iterate(ctx context.Context, fn func(Document) error) error {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, content, metadata, embedding FROM documents ORDER BY id")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		document, err := s.scanDocument(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(*document); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLite) scanDocument(row interface{ Scan(...any) error }) (*Document, error) {
+	document := &Document{}
+	var metadata string
+	var blob []byte
+	if err := row.Scan(&document.ID, &document.Content, &metadata, &blob); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(metadata), &document.Metadata); err != nil {
+		return nil, err
+	}
+	embedding, err := decodeVector(blob, s.encoding)
+	if err != nil {
+		return nil, fmt.Errorf("document %v: %w", document.ID, err)
+	}
+	document.Embedding = embedding
+	return document, nil
+}
+
+// normalize returns the vector with length 1
+func normalize(vector []float32) []float32 {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return vector
+	}
+	norm := float32(math.Sqrt(sum))
+	normalized := make([]float32, len(vector))
+	for i, v := range vector {
+		normalized[i] = v / norm
+	}
+	return normalized
+}
+
+// encodeVector stores the normalized vector as little endian float32 or
+// as float32 scale followed by one int8 per dimension
+func encodeVector(vector []float32, encoding string) ([]byte, error) {
+	vector = normalize(vector)
+	switch encoding {
+	case EncodingFloat32:
+		blob := make([]byte, 4*len(vector))
+		for i, v := range vector {
+			binary.LittleEndian.PutUint32(blob[4*i:], math.Float32bits(v))
+		}
+		return blob, nil
+	case EncodingInt8:
+		var largest float32
+		for _, v := range vector {
+			largest = max(largest, float32(math.Abs(float64(v))))
+		}
+		scale := largest / 127
+		blob := make([]byte, 4+len(vector))
+		binary.LittleEndian.PutUint32(blob, math.Float32bits(scale))
+		for i, v := range vector {
+			if scale > 0 {
+				blob[4+i] = byte(int8(math.Round(float64(v / scale))))
+			}
+		}
+		return blob, nil
+	}
+	return nil, fmt.Errorf("unknown vector encoding %q", encoding)
+}
+
+func decodeVector(blob []byte, encoding string) ([]float32, error) {
+	switch encoding {
+	case EncodingFloat32:
+		if len(blob)%4 != 0 {
+			return nil, errors.New("float32 vector has a partial value")
+		}
+		vector := make([]float32, len(blob)/4)
+		for i := range vector {
+			vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(blob[4*i:]))
+		}
+		return vector, nil
+	case EncodingInt8:
+		if len(blob) < 4 {
+			return nil, errors.New("int8 vector has no scale")
+		}
+		scale := math.Float32frombits(binary.LittleEndian.Uint32(blob))
+		vector := make([]float32, len(blob)-4)
+		for i := range vector {
+			vector[i] = float32(int8(blob[4+i])) * scale
+		}
+		return vector, nil
+	}
+	return nil, fmt.Errorf("unknown vector encoding %q", encoding)
+}
+
+// dotEncoded is the dot product of the question with the stored vector
+// without decoding it
+func dotEncoded(question []float32, blob []byte, encoding string) (float32, error) {
+	var sum float32
+	switch encoding {
+	case EncodingFloat32:
+		if len(blob) != 4*len(question) {
+			return 0, fmt.Errorf("vector has %d dimensions, the question %d", len(blob)/4, len(question))
+		}
+		for i, q := range question {
+			sum += q * math.Float32frombits(binary.LittleEndian.Uint32(blob[4*i:]))
+		}
+		return sum, nil
+	case EncodingInt8:
+		if len(blob) != 4+len(question) {
+			return 0, fmt.Errorf("vector has %d dimensions, the question %d", len(blob)-4, len(question))
+		}
+		scale := math.Float32frombits(binary.LittleEndian.Uint32(blob))
+		for i, q := range question {
+			sum += q * float32(int8(blob[4+i]))
+		}
+		return sum * scale, nil
+	}
+	return 0, fmt.Errorf("unknown vector encoding %q", encoding)
+}
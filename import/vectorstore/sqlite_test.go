@@ -0,0 +1,112 @@
+package vectorstore_test
+
+import (
+	"context"
+	"hugoembedding/embedding"
+	"hugoembedding/vectorstore"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestSQLite(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		// tolerance of the stored vector values
+		tolerance float64
+	}{
+		{"float32", vectorstore.EncodingFloat32, 1e-6},
+		{"int8", vectorstore.EncodingInt8, 0.01},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			embedder, err := embedding.NewHashing(64)
+			assert.NilError(t, err)
+			path := filepath.Join(t.TempDir(), "db.sqlite")
+			store, err := vectorstore.OpenSQLite(path, vectorstore.SQLiteOptions{Encoding: test.encoding}, ctx)
+			assert.NilError(t, err)
+
+			texts := []struct {
+				id, source, language, content string
+			}{
+				{"b-0000", "b.md", "en", "Scaling down EKS clusters at night"},
+				{"a-0000", "a.md", "en", "Finding EBS boot volumes"},
+				{"a-0001", "a.md", "de", "Boot volumes of instances are EBS volumes"},
+			}
+			var documents []vectorstore.Document
+			for _, text := range texts {
+				vector, err := embedding.Embed(ctx, embedder, text.content)
+				assert.NilError(t, err)
+				documents = append(documents, vectorstore.Document{
+					ID:        text.id,
+					Content:   text.content,
+					Embedding: vector,
+					Metadata:  map[string]string{"source": text.source, "language": text.language},
+				})
+			}
+			assert.NilError(t, store.Upsert(ctx, documents))
+			// the same ID replaces the document
+			documents[0].Content = "Scaling down EKS clusters"
+			assert.NilError(t, store.Upsert(ctx, documents[:1]))
+			count, err := store.Count(ctx)
+			assert.NilError(t, err)
+			assert.Equal(t, count, 3)
+			assert.NilError(t, store.Close())
+
+			// the lambda opens the file read only
+			store, err = vectorstore.OpenSQLite(path, vectorstore.SQLiteOptions{ReadOnly: true}, ctx)
+			assert.NilError(t, err)
+			document, err := store.Get(ctx, "b-0000")
+			assert.NilError(t, err)
+			assert.Equal(t, document.Content, "Scaling down EKS clusters")
+			assert.Equal(t, document.Metadata["source"], "b.md")
+			assert.Equal(t, len(document.Embedding), 64)
+			// the hashing vectors are normalized
+			for i, value := range document.Embedding {
+				assert.Assert(t, abs(value-documents[0].Embedding[i]) <= test.tolerance, "dimension %d", i)
+			}
+			document, err = store.Get(ctx, "c-0000")
+			assert.NilError(t, err)
+			assert.Assert(t, document == nil)
+			many, err := store.GetMany(ctx, []string{"a-0001", "c-0000", "b-0000"})
+			assert.NilError(t, err)
+			assert.Equal(t, len(many), 2)
+			assert.Equal(t, many["b-0000"].Content, "Scaling down EKS clusters")
+			assert.Equal(t, many["b-0000"].Metadata["source"], "b.md")
+
+			question, err := embedding.Embed(ctx, embedder, "EBS boot volumes")
+			assert.NilError(t, err)
+			results, err := store.Query(ctx, question, 2, nil)
+			assert.NilError(t, err)
+			assert.Equal(t, len(results), 2)
+			assert.Equal(t, results[0].ID, "a-0000")
+			assert.Assert(t, results[0].Similarity >= results[1].Similarity)
+			results, err = store.Query(ctx, question, 10, map[string]string{"source": "a.md", "language": "de"})
+			assert.NilError(t, err)
+			assert.Equal(t, len(results), 1)
+			assert.Equal(t, results[0].ID, "a-0001")
+			assert.NilError(t, store.Close())
+
+			store, err = vectorstore.OpenSQLite(path, vectorstore.DefaultSQLiteOptions, ctx)
+			assert.NilError(t, err)
+			assert.NilError(t, store.DeleteBySource(ctx, "b.md"))
+			ids := []string{}
+			assert.NilError(t, store.Iterate(ctx, func(document vectorstore.Document) error {
+				ids = append(ids, document.ID)
+				return nil
+			}))
+			assert.DeepEqual(t, ids, []string{"a-0000", "a-0001"})
+			assert.NilError(t, store.Close())
+		})
+	}
+}
+
+func abs(value float32) float64 {
+	if value < 0 {
+		return float64(-value)
+	}
+	return float64(value)
+}
@@ -0,0 +1,100 @@
+package vectorstore
+
+import (
+	"context"
+	"os"
+	"strconv"
+)
+
+// Document is a chunk with its embedding
+type Document struct {
+	// ID is stable across imports, see hugoembedding.DocumentID
+	ID        string
+	Content   string
+	Embedding []float32
+	Metadata  map[string]string
+}
+
+// Result is a document found by a query
+type Result struct {
+	Document
+	// Similarity is the cosine similarity to the query
+	Similarity float32
+}
+
+// VectorStore stores the documents of the import and finds the
+// documents which are similar to a question
+type VectorStore interface {
+	// Upsert adds the documents or replaces the documents with the same ID
+	Upsert(ctx context.Context, documents []Document) error
+	// DeleteBySource removes all documents of the markdown file, the
+	// documents store the path in the "source" metadata
+	DeleteBySource(ctx context.Context, source string) error
+	// Query returns the k documents most similar to the embedding,
+	// only documents whose metadata has all values of the filter are
+	// compared
+	Query(ctx context.Context, embedding []float32, k int, filter map[string]string) ([]Result, error)
+	// Get returns the document with the ID or nil if there is none
+	Get(ctx context.Context, id string) (*Document, error)
+	// GetMany returns the documents with the IDs by ID, IDs without a
+	// document are missing in the map
+	GetMany(ctx context.Context, ids []string) (map[string]*Document, error)
+	// Count returns the number of documents
+	Count(ctx context.Context) (int, error)
+	// Iterate calls fn for each document ordered by ID until fn
+	// returns an error
+	Iterate(ctx context.Context, fn func(Document) error) error
+	// Close writes pending changes and releases the store
+	Close() error
+}
+
+const (
+	// KindChromem is the chromem-go database file bundled with the lambda
+	KindChromem = "chromem"
+	// KindPgvector is a Postgres database with the pgvector extension
+	KindPgvector = "pgvector"
+	// KindSQLite is a SQLite database file bundled with the lambda
+	KindSQLite = "sqlite"
+)
+
+// Config selects the vector store
+type Config struct {
+	// Kind is KindChromem, KindPgvector or KindSQLite
+	Kind string
+	// DatabaseURL is the Postgres connection string of pgvector
+	DatabaseURL string
+	Postgres    PostgresOptions
+	SQLite      SQLiteOptions
+}
+
+// DefaultConfig stores the documents in a chromem database file
+func DefaultConfig() Config {
+	return Config{Kind: KindChromem, Postgres: DefaultPostgresOptions, SQLite: DefaultSQLiteOptions}
+}
+
+// ConfigFromEnv overrides the configuration with VECTOR_STORE,
+// DATABASE_URL and the PGVECTOR_ variables
+func ConfigFromEnv(cfg Config) Config {
+	if kind := os.Getenv("VECTOR_STORE"); kind != "" {
+		cfg.Kind = kind
+	}
+	if url := os.Getenv("DATABASE_URL"); url != "" {
+		cfg.DatabaseURL = url
+	}
+	if table := os.Getenv("PGVECTOR_TABLE"); table != "" {
+		cfg.Postgres.Table = table
+	}
+	if index := os.Getenv("PGVECTOR_INDEX"); index != "" {
+		cfg.Postgres.Index = index
+	}
+	if dimensions, err := strconv.Atoi(os.Getenv("PGVECTOR_DIMENSIONS")); err == nil {
+		cfg.Postgres.Dimensions = dimensions
+	}
+	if efSearch, err := strconv.Atoi(os.Getenv("PGVECTOR_EF_SEARCH")); err == nil {
+		cfg.Postgres.EfSearch = efSearch
+	}
+	if probes, err := strconv.Atoi(os.Getenv("PGVECTOR_PROBES")); err == nil {
+		cfg.Postgres.Probes = probes
+	}
+	return cfg
+}